@@ -1,7 +1,10 @@
 package GUI
 
 import (
+	_ "embed"
+	"errors"
 	"image/color"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
@@ -18,6 +21,7 @@ import (
 	"gioui.org/widget/material"
 	"github.com/benoitkugler/pen2latex/GUI/views"
 	"github.com/benoitkugler/pen2latex/symbols"
+	"golang.org/x/image/font/sfnt"
 )
 
 type (
@@ -25,26 +29,116 @@ type (
 	D = layout.Dimensions
 )
 
-func loadStore() symbols.Store {
+// rejectionThreshold is the minimum confidence
+// required to insert a symbol
+const rejectionThreshold = 0.1
+
+const (
+	storeFile       = "pen2latex.store"
+	legacyStoreFile = "pen2latex.store.json" // used before the binary format
+	teamStoreFile   = "pen2latex.team.store" // optional, shared by a team
+)
+
+// baseStore is the read-only store bundled with the application,
+// built (and calibrated) from the samples of base_samples.json.
+//
+//go:generate go run ../cmd/basestore -o base.store base_samples.json
+//go:embed base.store
+var baseStore []byte
+
+// loadStore combines the bundled base store (completed with the glyphs of [mathFont]),
+// the team store and the user store, if they exist.
+func loadStore(mathFont []byte) symbols.Store {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		panic(err) // TODO:
 	}
-	storePath := filepath.Join(homeDir, "pen2latex.store.json")
+
+	user := loadUserStore(homeDir)
+
+	team, err := symbols.NewStoreFromDisk(filepath.Join(homeDir, teamStoreFile))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Println(err)
+	}
+
+	base := loadBaseStore(mathFont)
+
+	database := symbols.NewLayeredStore(base, team, user)
+	database.Rejection = rejectionThreshold
+	// the bundled samples are upright, but users may write in italic
+	database.Deslant = true
+	if database.Calibration.IsZero() {
+		// the calibration of the base store is computed by its generator,
+		// and the store is calibrated again when the user edits it,
+		// so that the (quadratic) calibration is not run at each startup
+		database.Calibration = base.Calibration
+	}
+	if database.Calibration.IsZero() { // the base store is invalid
+		database.Calibrate()
+	}
+	return database
+}
+
+func loadUserStore(homeDir string) symbols.Store {
+	storePath := filepath.Join(homeDir, storeFile)
 	database, err := symbols.NewStoreFromDisk(storePath) // TODO:
+	if errors.Is(err, fs.ErrNotExist) {
+		// migrate the legacy store, which is kept as backup
+		legacyPath := filepath.Join(homeDir, legacyStoreFile)
+		if database, err = symbols.NewStoreFromDisk(legacyPath); err == nil {
+			log.Println("Migrating store from", legacyPath)
+			saveStore(database)
+		}
+	}
 	if err != nil {
-		log.Println(err)
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Println(err)
+		}
 		database = symbols.NewStore(nil)
 	}
 	return database
 }
 
+// loadBaseStore loads the bundled store, using the glyphs
+// of [mathFont] for the missing runes
+func loadBaseStore(mathFont []byte) symbols.Store {
+	base, err := symbols.NewStoreFromBytes(baseStore)
+	if err != nil {
+		log.Println(err)
+	}
+
+	glyphs, err := newStoreFromFont(mathFont)
+	if err != nil {
+		log.Println(err)
+		return base
+	}
+	var missing []symbols.LabelledSymbol
+	glyphs.Range(func(r rune, samples []symbols.RuneFootprint) bool {
+		if len(base.Samples(r)) == 0 {
+			for _, sample := range samples {
+				missing = append(missing, symbols.LabelledSymbol{R: r, Symbol: sample.Symbol})
+			}
+		}
+		return true
+	})
+	base.AddSamples(missing)
+	return base
+}
+
+func newStoreFromFont(fontFile []byte) (symbols.Store, error) {
+	font, err := sfnt.Parse(fontFile)
+	if err != nil {
+		return symbols.Store{}, err
+	}
+	return symbols.NewStoreFromFont(font, views.RequiredRunes)
+}
+
 func saveStore(st symbols.Store) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		panic(err) // TODO:
 	}
-	storePath := filepath.Join(homeDir, "pen2latex.store.json")
+	storePath := filepath.Join(homeDir, storeFile)
 	err = st.Serialize(storePath)
 	if err != nil {
 		panic(err) // TODO:
@@ -68,7 +162,7 @@ func Run(w *app.Window) error {
 	fonts = append(fonts, text.FontFace{Face: mathFont})
 
 	th := material.NewTheme(fonts)
-	store := loadStore()
+	store := loadStore(fontFile)
 
 	var homeMenu menu
 
@@ -95,8 +189,10 @@ func Run(w *app.Window) error {
 			} else if symbols.BackButton.Clicked() {
 				view = viewHome
 			} else if editor.BackButton.Clicked() {
+				editor.Cancel()
 				view = viewHome
 			} else if sandbox.BackButton.Clicked() {
+				sandbox.Cancel()
 				view = viewHome
 			}
 
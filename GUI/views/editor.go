@@ -1,12 +1,14 @@
 package views
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"image/color"
 
 	"gioui.org/io/pointer"
 	"gioui.org/layout"
+	"gioui.org/op"
 	"gioui.org/op/clip"
 	"gioui.org/op/paint"
 	"gioui.org/widget"
@@ -34,6 +36,12 @@ type Editor struct {
 	context la.Context
 
 	rec la.Recorder
+
+	// the recognition of the last stroke, if any
+	pending *insertion
+
+	// true if the last input has not been recognized
+	rejected bool
 }
 
 const (
@@ -48,13 +56,29 @@ func NewEditor(store *sy.Store, theme *material.Theme) *Editor {
 func (ed *Editor) Layout(gtx C) D {
 	// event handling
 	if ed.resetButton.Clicked() {
+		ed.Cancel()
 		ed.rec.Reset()
 		ed.line = la.NewLine(sy.Rect{sy.Pos{}, sy.Pos{width, height}})
 		ed.context = la.Context{}
+		ed.rejected = false
+	}
+
+	if ed.pending != nil {
+		select {
+		case res := <-ed.pending.result:
+			ed.pending.cancel() // release the context
+			ed.pending = nil
+			if res.err == nil {
+				ed.applyInsertion(res.ins)
+			}
+		default: // wait for the next frame
+			op.InvalidateOp{}.Add(gtx.Ops)
+		}
 	}
 
 	return layout.Flex{Axis: layout.Vertical, Spacing: layout.SpaceEvenly}.Layout(gtx,
 		layout.Rigid(ed.layoutLine),
+		layout.Rigid(ed.layoutRejected),
 		layout.Rigid(material.Body1(ed.theme, fmt.Sprintf("Expression : %s", ed.line.LaTeX())).Layout),
 		layout.Rigid(sh.WithPadding(10, sh.Button(ed.theme, &ed.resetButton, "Effacer", sh.NegativeAction).Layout)),
 		layout.Rigid(sh.WithPadding(10, material.Button(ed.theme, &ed.BackButton, "Retour").Layout)),
@@ -89,7 +113,8 @@ func (ed *Editor) layoutLine(gtx C) D {
 				ed.rec.EndShape()
 				ed.onStroke()
 			case pointer.Drag:
-				ed.rec.AddToShape(sy.Pos{X: ev.Position.X, Y: ev.Position.Y})
+				// pressure is not reported by Gio
+				ed.rec.AddTimedPoint(sy.Pos{X: ev.Position.X, Y: ev.Position.Y}, ev.Time, 0)
 			}
 		}
 	}
@@ -109,8 +134,52 @@ func (ed *Editor) layoutLine(gtx C) D {
 	return D{Size: size}
 }
 
+// insertionResult is the output of [la.Line.IdentifyContext]
+type insertionResult struct {
+	ins la.Insertion
+	err error
+}
+
+// insertion runs the recognition of a record in the background
+type insertion struct {
+	cancel context.CancelFunc
+	result chan insertionResult // receives exactly one value
+}
+
+// startInsertion uses a snapshot of [store], so that it may be edited
+// while the recognition runs. [line] must not be modified until the result is received.
+func startInsertion(line *la.Line, rec la.Record, store sy.Store) *insertion {
+	ctx, cancel := context.WithCancel(context.Background())
+	out := &insertion{cancel: cancel, result: make(chan insertionResult, 1)}
+	go func() {
+		ins, err := line.IdentifyContext(ctx, rec, &store)
+		out.result <- insertionResult{ins: ins, err: err}
+	}()
+	return out
+}
+
+// Cancel stops the recognition in progress, if any,
+// and waits for it to return, so that the line may be modified.
+func (ed *Editor) Cancel() {
+	if ed.pending == nil {
+		return
+	}
+	ed.pending.cancel()
+	<-ed.pending.result
+	ed.pending = nil
+}
+
 func (ed *Editor) onStroke() {
-	status := ed.line.Insert(ed.rec.Record, ed.store)
+	// a new stroke makes the previous recognition stale
+	ed.Cancel()
+	rec := append(la.Record(nil), ed.rec.Record...)
+	ed.pending = startInsertion(ed.line, rec, ed.store.Clone())
+}
+
+// applyInsertion updates the line and the recorder
+func (ed *Editor) applyInsertion(ins la.Insertion) {
+	status := ed.line.Apply(ins)
+	ed.rejected = status == la.Rejected
 	// update the recorder
 	switch status {
 	case la.KeepAll: // nothing to do
@@ -118,7 +187,19 @@ func (ed *Editor) onStroke() {
 		ed.rec.DropButLast()
 	case la.RemoveAll: // keep nothing
 		ed.rec.Reset()
+	case la.Rejected: // keep the strokes, the user may complete or erase them
+	}
+}
+
+// layoutRejected asks the user to complete or erase the current input
+// when it has not been recognized
+func (ed *Editor) layoutRejected(gtx C) D {
+	if !ed.rejected {
+		return D{}
 	}
+	label := material.Body1(ed.theme, "Symbole non reconnu : complétez-le ou effacez.")
+	label.Color = color.NRGBA{R: 200, A: 255}
+	return label.Layout(gtx)
 }
 
 func (ed *Editor) drawContext(gtx C) {
@@ -1,9 +1,12 @@
 package views
 
 import (
+	"context"
 	"fmt"
+	"strings"
 
 	"gioui.org/layout"
+	"gioui.org/op"
 	"gioui.org/widget"
 	"gioui.org/widget/material"
 	sh "github.com/benoitkugler/pen2latex/GUI/shared"
@@ -20,8 +23,11 @@ type Sandbox struct {
 
 	store *symbols.Store
 
-	wb      whiteboard.Whiteboard
-	matched rune
+	wb           whiteboard.Whiteboard
+	pending      *recognition // the recognition of the last stroke, if any
+	matched      rune
+	alternatives []symbols.Candidate
+	branch       string // the decision taken by the recognition
 
 	resetButton widget.Clickable
 
@@ -29,33 +35,77 @@ type Sandbox struct {
 	BackButton widget.Clickable
 }
 
+// maxAlternatives is the number of candidates displayed
+const maxAlternatives = 5
+
 func NewSandbox(store *symbols.Store, theme *material.Theme) *Sandbox {
 	return &Sandbox{theme: theme, store: store}
 }
 
+// recognitionResult is the output of [la.Record.IdentifyTraceContext]
+type recognitionResult struct {
+	r      rune
+	action la.RecordAction
+	trace  la.IdentifyTrace
+	err    error
+}
+
+// recognition runs the identification of a record in the background
+type recognition struct {
+	cancel context.CancelFunc
+	result chan recognitionResult // receives exactly one value
+}
+
+// startRecognition uses a snapshot of [store], so that it may be edited
+// while the recognition runs
+func startRecognition(rec la.Record, store symbols.Store, grid symbols.HeightGrid) *recognition {
+	ctx, cancel := context.WithCancel(context.Background())
+	out := &recognition{cancel: cancel, result: make(chan recognitionResult, 1)}
+	go func() {
+		r, action, _, trace, err := rec.IdentifyTraceContext(ctx, &store, grid)
+		out.result <- recognitionResult{r: r, action: action, trace: trace, err: err}
+	}()
+	return out
+}
+
+// Cancel stops the recognition in progress, if any,
+// and waits for it to return.
+func (ed *Sandbox) Cancel() {
+	if ed.pending == nil {
+		return
+	}
+	ed.pending.cancel()
+	<-ed.pending.result
+	ed.pending = nil
+}
+
 func (ed *Sandbox) Layout(gtx C) D {
 	// event handling
 	if ed.resetButton.Clicked() {
+		ed.Cancel()
 		ed.wb.Reset()
 		ed.matched = 0
+		ed.alternatives = nil
+		ed.branch = ""
 	}
 
 	if ok := ed.wb.HasNewShape(); ok {
-		// udapte the recognized rune
-		rec := ed.wb.Record()
-		r, onlyLastUsed, _ := rec.Identify(ed.store, ed.wb.Context())
-		ed.matched = r
-
-		fmt.Println(rec)
-
-		// drop the old strokes when we are sure they
-		// are not part of a compound symbol
-		switch onlyLastUsed {
-		case la.KeepAll: // nothing to do
-		case la.KeepLast: // keep the last
-			ed.wb.DropButLast()
-		case la.RemoveAll: // keep nothing
-			ed.wb.Reset()
+		// a new stroke makes the previous recognition stale
+		ed.Cancel()
+		rec := append(la.Record(nil), ed.wb.Record()...)
+		ed.pending = startRecognition(rec, ed.store.Clone(), ed.wb.Context())
+	}
+
+	if ed.pending != nil {
+		select {
+		case res := <-ed.pending.result:
+			ed.pending.cancel() // release the context
+			ed.pending = nil
+			if res.err == nil {
+				ed.setResult(res)
+			}
+		default: // wait for the next frame
+			op.InvalidateOp{}.Add(gtx.Ops)
 		}
 	}
 
@@ -63,9 +113,51 @@ func (ed *Sandbox) Layout(gtx C) D {
 		layout.Rigid(sh.Flex(
 			layout.Flex{Axis: layout.Horizontal, Spacing: layout.SpaceEvenly, Alignment: layout.Middle},
 			layout.Rigid(ed.wb.Layout),
-			layout.Rigid(material.Body1(ed.theme, fmt.Sprintf("Caractère reconnu : %s", string(ed.matched))).Layout),
+			layout.Rigid(material.Body1(ed.theme, fmt.Sprintf("Caractère reconnu : %s", formatRune(ed.matched))).Layout),
 		)),
+		layout.Rigid(material.Body2(ed.theme, fmt.Sprintf("Alternatives : %s", formatCandidates(ed.alternatives))).Layout),
+		layout.Rigid(material.Body2(ed.theme, fmt.Sprintf("Décision : %s", ed.branch)).Layout),
 		layout.Rigid(sh.WithPadding(10, sh.Button(ed.theme, &ed.resetButton, "Effacer", sh.NegativeAction).Layout)),
 		layout.Rigid(sh.WithPadding(10, material.Button(ed.theme, &ed.BackButton, "Retour").Layout)),
 	)
 }
+
+// setResult updates the recognized rune
+func (ed *Sandbox) setResult(res recognitionResult) {
+	ed.matched = res.r
+	// the alternatives are given by the lookup used for the result
+	ed.alternatives = res.trace.Candidates()
+	if len(ed.alternatives) > maxAlternatives {
+		ed.alternatives = ed.alternatives[:maxAlternatives]
+	}
+	ed.branch = res.trace.Branch.String()
+
+	// drop the old strokes when we are sure they
+	// are not part of a compound symbol
+	switch res.action {
+	case la.KeepAll: // nothing to do
+	case la.KeepLast: // keep the last
+		ed.wb.DropButLast()
+	case la.RemoveAll: // keep nothing
+		ed.wb.Reset()
+	case la.Rejected: // keep the strokes
+	}
+}
+
+func formatCandidates(candidates []symbols.Candidate) string {
+	chunks := make([]string, len(candidates))
+	for i, c := range candidates {
+		chunks[i] = fmt.Sprintf("%s (%.0f%%)", string(c.R), 100*c.Confidence)
+		if c.Compatible {
+			chunks[i] += "..."
+		}
+	}
+	return strings.Join(chunks, ", ")
+}
+
+func formatRune(r rune) string {
+	if r == la.Unknown {
+		return "?"
+	}
+	return string(r)
+}
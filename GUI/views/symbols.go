@@ -42,11 +42,13 @@ type Store struct {
 
 type symbolList struct {
 	list        widget.List
-	editButtons []widget.Clickable
+	editButtons []widget.Clickable // one per rune
+	addButtons  []widget.Clickable // one per rune
 
 	runeField        widget.Editor
 	addButton        widget.Clickable
 	resetStoreButton widget.Clickable
+	refitButton      widget.Clickable
 }
 
 type symbolEditor struct {
@@ -54,14 +56,15 @@ type symbolEditor struct {
 	validButton widget.Clickable
 	resetButton widget.Clickable
 	backButton  widget.Clickable
-	index       int
+
+	r         rune // the rune being edited
+	addSample bool // if false, the samples for [r] are replaced
 }
 
 func NewStore(store *sy.Store, th *material.Theme) Store {
 	out := Store{store: store, theme: th}
 
 	out.list.list.Axis = layout.Vertical
-	out.list.editButtons = make([]widget.Clickable, len(store.Symbols))
 	out.list.runeField = widget.Editor{Alignment: text.Middle, SingleLine: true, Submit: true, MaxLen: 1}
 
 	out.editor.editor = whiteboard.NewWhiteboard(th)
@@ -79,23 +82,39 @@ func (fl *Store) Layout(gtx C) D {
 	}
 
 	if fl.viewKind == viewCreate && fl.creator.isDone() {
-		// use the new store
-		*fl.store = sy.NewStore(fl.creator.symbols)
-		fl.list.editButtons = make([]widget.Clickable, len(fl.store.Symbols))
+		// use the new store as user layer, keeping the other layers and the settings
+		user := fl.creator.symbols
+		user.Rejection = fl.store.Rejection
+		user.Deslant, user.Rotation, user.Distance = fl.store.Deslant, fl.store.Rotation, fl.store.Distance
+		newStore := sy.NewLayeredStore(fl.store.Layer(sy.BaseLayer), fl.store.Layer(sy.TeamLayer), user)
+		newStore.Calibrate()
+		newStore.LearnSizes()
+		*fl.store = newStore
 
 		fl.viewKind = viewList
 	}
 
 	if fl.editor.validButton.Clicked() {
 		// commit the changes
-		fmt.Println(fl.editor.editor.Record())
-
-		fl.store.Symbols[fl.editor.index].Footprint = fl.editor.editor.Footprint()
+		symbol, info, grid := sy.Symbol(fl.editor.editor.Record()), fl.editor.editor.Info(), fl.editor.editor.Context()
+		if fl.editor.addSample {
+			fl.store.AddWithInfo(fl.editor.r, symbol, info, grid)
+		} else {
+			fl.store.ReplaceWithInfo(fl.editor.r, symbol, info, grid)
+		}
+		fl.store.Calibrate()
+		fl.store.LearnSizes()
 		fl.editor.editor.Reset()
 
 		fl.viewKind = viewList
 	}
 
+	if fl.list.refitButton.Clicked() {
+		// use the current fitting algorithm
+		fl.store.Refit()
+		fl.store.Calibrate()
+	}
+
 	if fl.editor.resetButton.Clicked() {
 		fl.editor.editor.Reset()
 	}
@@ -107,11 +126,7 @@ func (fl *Store) Layout(gtx C) D {
 		r, _ := utf8.DecodeRuneInString(fl.list.runeField.Text())
 		fl.list.runeField.SetText("")
 
-		fl.store.Symbols = append(fl.store.Symbols, sy.RuneFootprint{R: r})
-		fl.list.editButtons = append(fl.list.editButtons, widget.Clickable{})
-		fl.editor.index = len(fl.store.Symbols) - 1
-
-		fl.viewKind = viewEdit
+		fl.startEdit(r, true)
 	}
 
 	switch fl.viewKind {
@@ -132,19 +147,30 @@ func (fl *Store) layoutList(gtx C) D {
 
 	reset := sh.Button(fl.theme, &fl.list.resetStoreButton, "Ré-initialiser", sh.NegativeAction)
 
+	refit := material.Button(fl.theme, &fl.list.refitButton, "Ré-analyser les symboles")
+
+	// group the samples by rune
+	var items [][]sy.RuneFootprint
+	fl.store.Range(func(_ rune, samples []sy.RuneFootprint) bool {
+		items = append(items, samples)
+		return true
+	})
+	for len(fl.list.editButtons) < len(items) {
+		fl.list.editButtons = append(fl.list.editButtons, widget.Clickable{})
+		fl.list.addButtons = append(fl.list.addButtons, widget.Clickable{})
+	}
+
 	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
 		layout.Flexed(8, func(gtx layout.Context) layout.Dimensions {
-			return material.List(fl.theme, &fl.list.list).Layout(gtx, len(fl.store.Symbols), func(gtx C, index int) D {
-				item := fl.store.Symbols[index]
-				btn := &fl.list.editButtons[index]
-				if btn.Clicked() {
-					// reset to not mix runes
-					fl.editor.editor.Reset()
-					fl.editor.index = index
-
-					fl.viewKind = viewEdit
+			return material.List(fl.theme, &fl.list.list).Layout(gtx, len(items), func(gtx C, index int) D {
+				samples := items[index]
+				editBtn, addBtn := &fl.list.editButtons[index], &fl.list.addButtons[index]
+				if editBtn.Clicked() {
+					fl.startEdit(samples[0].R, false)
+				} else if addBtn.Clicked() {
+					fl.startEdit(samples[0].R, true)
 				}
-				return layoutFootprintCard(item, btn, gtx, fl.theme)
+				return layoutFootprintCard(samples, editBtn, addBtn, gtx, fl.theme)
 			})
 		}),
 		layout.Rigid(sh.WithPadding(5, func(gtx C) D {
@@ -158,14 +184,26 @@ func (fl *Store) layoutList(gtx C) D {
 				layout.Rigid(sh.WithPadding(5, material.Editor(fl.theme, &fl.list.runeField, "Nouveau symbol").Layout)),
 			)
 		})),
+		layout.Rigid(sh.WithPadding(5, refit.Layout)),
 		layout.Rigid(sh.WithPadding(5, reset.Layout)),
 		layout.Rigid(material.Button(fl.theme, &fl.BackButton, "Retour").Layout),
 	)
 }
 
+// startEdit switches to the edit view for [r]
+func (fl *Store) startEdit(r rune, addSample bool) {
+	// reset to not mix runes
+	fl.editor.editor.Reset()
+	fl.editor.r = r
+	fl.editor.addSample = addSample
+
+	fl.viewKind = viewEdit
+}
+
 func (fl *Store) layoutEdit(gtx C) D {
 	return sh.Padding(10).Layout(gtx, func(gtx C) D {
 		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(material.H5(fl.theme, "Symbol : "+string(fl.editor.r)).Layout),
 			layout.Rigid(func(gtx C) D {
 				return layout.Flex{Axis: layout.Horizontal, Spacing: layout.SpaceAround}.Layout(gtx, layout.Rigid(fl.editor.editor.Layout))
 			}),
@@ -181,26 +219,27 @@ func (fl *Store) layoutCreate(gtx C) D { return fl.creator.layout(gtx) }
 type storeCreation struct {
 	theme *material.Theme
 
-	toRegister    []rune             // the symbols the user have to provide
-	currentSymbol int                // index  in [toRegister]
-	symbols       map[rune]sy.Symbol // the symbol drawn so far
+	toRegister    []rune   // the symbols the user have to provide
+	currentSymbol int      // index  in [toRegister]
+	symbols       sy.Store // the symbol drawn so far
 
 	editor      *whiteboard.Whiteboard
 	validButton widget.Clickable // go to next
 	resetButton widget.Clickable
 }
 
-var requiredRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_×+-=()[]∈Σℝπ")
+// RequiredRunes are the runes asked when creating a new store
+var RequiredRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_×+-=()[]∈Σℝπ")
 
-// var requiredRunes = []rune("abcdefxySoit()123_∈Σℝ")
+// var RequiredRunes = []rune("abcdefxySoit()123_∈Σℝ")
 
-// var requiredRunes = []rune("a")
+// var RequiredRunes = []rune("a")
 
 func newStoreCreation(theme *material.Theme) storeCreation {
 	return storeCreation{
-		toRegister: requiredRunes,
-		theme:      theme, symbols: make(map[rune]sy.Symbol),
-		editor: whiteboard.NewWhiteboard(theme),
+		toRegister: RequiredRunes,
+		theme:      theme,
+		editor:     whiteboard.NewWhiteboard(theme),
 	}
 }
 
@@ -213,7 +252,7 @@ func (sc *storeCreation) layout(gtx C) D {
 
 	if sc.validButton.Clicked() {
 		r := sc.toRegister[sc.currentSymbol]
-		sc.symbols[r] = sy.Symbol(sc.editor.Record())
+		sc.symbols.AddWithInfo(r, sy.Symbol(sc.editor.Record()), sc.editor.Info(), sc.editor.Context())
 
 		sc.currentSymbol++
 		sc.editor.Reset()
@@ -241,7 +280,13 @@ func (sc *storeCreation) layout(gtx C) D {
 	})
 }
 
-func layoutFootprintCard(fp sy.RuneFootprint, btn *widget.Clickable, gtx C, th *material.Theme) D {
+// layoutFootprintCard shows the first sample of a rune, and its layer
+func layoutFootprintCard(samples []sy.RuneFootprint, editBtn, addBtn *widget.Clickable, gtx C, th *material.Theme) D {
+	fp := samples[0]
+	description := fmt.Sprintf("%s\n(u+%04X)\n%d exemple(s)", string(fp.R), fp.R, len(samples))
+	if fp.Layer != sy.UserLayer { // samples shared by the team or bundled
+		description += fmt.Sprintf("\n(%s)", fp.Layer)
+	}
 	borderColor := color.NRGBA{0, 200, 100, 255}
 	border := widget.Border{Color: borderColor, CornerRadius: 10, Width: 1}
 	return sh.Padding(5).Layout(gtx, func(gtx C) D {
@@ -249,9 +294,11 @@ func layoutFootprintCard(fp sy.RuneFootprint, btn *widget.Clickable, gtx C, th *
 			return sh.Padding(10).Layout(gtx, func(gtx C) D {
 				return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
 					layout.Flexed(1, sh.Column(
-						layout.Rigid(material.Body1(th, fmt.Sprintf("%s\n(u+%04X)", string(fp.R), fp.R)).Layout),
+						layout.Rigid(material.Body1(th, description).Layout),
 						layout.Rigid(layout.Spacer{Height: 20}.Layout),
-						layout.Rigid(material.Button(th, btn, "Modifier").Layout),
+						layout.Rigid(material.Button(th, editBtn, "Modifier").Layout),
+						layout.Rigid(layout.Spacer{Height: 5}.Layout),
+						layout.Rigid(material.Button(th, addBtn, "Ajouter un exemple").Layout),
 					)),
 					layout.Rigid(sh.WithPadding(20, footprint{fp.Footprint}.layout)),
 				)
@@ -36,6 +36,9 @@ func NewWhiteboard(theme *material.Theme) *Whiteboard { return &Whiteboard{theme
 func (b *Whiteboard) Footprint() sy.Footprint { return b.footprint }
 func (b *Whiteboard) Record() la.Record       { return b.recorder.Record }
 
+// Info returns the timestamps (and pressure) of the points of [Record]
+func (b *Whiteboard) Info() sy.SymbolInfo { return b.recorder.Info }
+
 const (
 	width    = 400
 	height   = 90
@@ -90,7 +93,8 @@ func (b *Whiteboard) Layout(gtx C) D {
 				b.footprint = sy.Symbol(b.recorder.Record).Footprint()
 				b.newShape = true
 			case pointer.Drag:
-				b.recorder.AddToShape(sy.Pos{X: x.Position.X, Y: x.Position.Y})
+				// pressure is not reported by Gio
+				b.recorder.AddTimedPoint(sy.Pos{X: x.Position.X, Y: x.Position.Y}, x.Time, 0)
 			}
 		}
 	}
@@ -0,0 +1,81 @@
+// Command basestore generates the read-only store bundled with the GUI
+// (see the base layer in GUI/app.go) from a JSON file of raw samples :
+//
+//	{"a": [symbol, ...], ...}
+//
+// where a symbol is a list of strokes, and a stroke a list of [x, y] points.
+//
+// The samples are fitted with the current algorithm, and the store is calibrated,
+// so that the application does not need to do it at startup.
+//
+// Usage :
+//
+//	go run ./cmd/basestore -o GUI/base.store GUI/base_samples.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"unicode/utf8"
+
+	sy "github.com/benoitkugler/pen2latex/symbols"
+)
+
+func main() {
+	output := flag.String("o", "base.store", "the store file to generate")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		log.Fatal("usage: basestore [-o base.store] samples.json")
+	}
+
+	samples, err := loadSamples(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var db sy.Store
+	db.AddSamples(samples)
+	db.Calibrate()
+	if err = db.Serialize(*output); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%d samples written in %s", len(db.Symbols), *output)
+}
+
+// loadSamples reads the samples from [filename], sorted by rune
+func loadSamples(filename string) ([]sy.LabelledSymbol, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string][][][][2]sy.Fl
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid samples file: %s", err)
+	}
+
+	var out []sy.LabelledSymbol
+	for key, symbols := range raw {
+		r, size := utf8.DecodeRuneInString(key)
+		if size == 0 || size != len(key) {
+			return nil, fmt.Errorf("invalid samples file: %q is not one rune", key)
+		}
+		for _, strokes := range symbols {
+			symbol := make(sy.Symbol, len(strokes))
+			for i, points := range strokes {
+				shape := make(sy.Shape, len(points))
+				for j, p := range points {
+					shape[j] = sy.Pos{X: p[0], Y: p[1]}
+				}
+				symbol[i] = shape
+			}
+			out = append(out, sy.LabelledSymbol{R: r, Symbol: symbol})
+		}
+	}
+	// the samples of one rune keep their order
+	sort.SliceStable(out, func(i, j int) bool { return out[i].R < out[j].R })
+	return out, nil
+}
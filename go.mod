@@ -4,7 +4,7 @@ go 1.19
 
 require gioui.org v0.0.0-20230206180804-32c6a9b10d0b
 
-require golang.org/x/image v0.6.0 // indirect
+require golang.org/x/image v0.6.0
 
 require (
 	gioui.org/cpu v0.0.0-20210817075930-8d6a761490d2 // indirect
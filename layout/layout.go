@@ -65,6 +65,17 @@ func newNode(height sy.HeightGrid, x Fl) *Node {
 	return &Node{height: height, initialX: x}
 }
 
+// grid returns the height of the node, with its typographic
+// lines estimated from the symbols already written in it
+func (n *Node) grid() sy.HeightGrid {
+	written := make([]sy.RuneFootprint, len(n.blocks))
+	for i, bl := range n.blocks {
+		gr := bl.Grapheme()
+		written[i] = sy.RuneFootprint{R: gr.Char, Footprint: gr.Symbol}
+	}
+	return n.height.Estimate(written)
+}
+
 // boxes return two boxes delimiting the content of the node
 // and its children
 // [inner] only accounts for the actual drawn symbols,
@@ -3,7 +3,12 @@
 // used to recognize and correctly insert a user input
 package layout
 
-import "testing"
+import (
+	"testing"
+
+	sy "github.com/benoitkugler/pen2latex/symbols"
+	tu "github.com/benoitkugler/pen2latex/testutils"
+)
 
 func TestNode_insertAt(t *testing.T) {
 	tests := []struct {
@@ -20,3 +25,14 @@ func TestNode_insertAt(t *testing.T) {
 		n.insertAt(tt.bl, tt.index)
 	}
 }
+
+func TestNode_grid(t *testing.T) {
+	n := newNode(baselineFromHeight(0, 60), 0)
+	tu.AssertEqual(t, n.grid(), n.height)
+
+	x := sy.Footprint{Strokes: []sy.Stroke{{Curves: []sy.Bezier{{P0: sy.Pos{X: 0, Y: 30}, P1: sy.Pos{X: 5, Y: 35}, P2: sy.Pos{X: 10, Y: 38}, P3: sy.Pos{X: 15, Y: 42}}}}}}
+	n.insertAt(newRegularChar(grapheme{Symbol: x, Char: 'x'}), 0)
+	grid := n.grid()
+	tu.AssertEqual(t, grid.XHeight, n.height.Baseline-30)
+	tu.AssertEqual(t, grid.CapHeight, Fl(0))
+}
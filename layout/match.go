@@ -1,7 +1,7 @@
 package layout
 
 import (
-	"fmt"
+	"context"
 
 	sy "github.com/benoitkugler/pen2latex/symbols"
 )
@@ -24,31 +24,80 @@ func (line *Line) FindContext(pos sy.Pos) Context {
 // Insert finds the best place in [line] to insert [content],
 // and updates the line.
 // It also returns how the current record should be updated.
+// If the record is not recognized, the line is not modified and [Rejected] is returned.
 func (line *Line) Insert(rec Record, db *sy.Store) RecordAction {
+	return line.Apply(line.identify(nil, rec, db, nil))
+}
+
+// InsertTrace is the same as [Line.Insert], but also returns
+// the report of the recognition, see [Record.IdentifyTrace].
+func (line *Line) InsertTrace(rec Record, db *sy.Store) (RecordAction, IdentifyTrace) {
+	trace := new(IdentifyTrace)
+	action := line.Apply(line.identify(nil, rec, db, trace))
+	return action, *trace
+}
+
+// Insertion is the result of the recognition of a record,
+// which is applied with [Line.Apply].
+type Insertion struct {
+	rec        Record
+	node       *Node
+	insertPos  int
+	r          rune
+	action     RecordAction
+	isCompound bool
+}
+
+// Action returns how the record should be updated
+// once the insertion is applied.
+func (ins Insertion) Action() RecordAction { return ins.action }
+
+// IdentifyContext performs the recognition done by [Line.Insert], without
+// modifying the line, using [Record.IdentifyTraceContext], so that it may be run
+// in a background goroutine, and stopped early when [ctx] is cancelled.
+//
+// The line must not be modified until the returned value is applied with [Line.Apply].
+func (line *Line) IdentifyContext(ctx context.Context, rec Record, db *sy.Store) (Insertion, error) {
+	ins := line.identify(ctx, rec, db, nil)
+	if err := ctx.Err(); err != nil {
+		return Insertion{}, err
+	}
+	return ins, nil
+}
+
+// identify fills [trace] if it is not nil, and uses the concurrent
+// lookups if [ctx] is not nil
+func (line *Line) identify(ctx context.Context, rec Record, db *sy.Store, trace *IdentifyTrace) Insertion {
 	// find the correct scope
 	_, last := rec.split()
 	node, insertPos := line.findNode(last.BoundingBox())
-	fmt.Printf("insert at index %v in node %p\n", insertPos, node)
 
-	r, action, isCompound := rec.Identify(db, node.height)
+	r, action, isCompound := rec.identifyTrace(ctx, db, node.grid(), trace)
+	return Insertion{rec: rec, node: node, insertPos: insertPos, r: r, action: action, isCompound: isCompound}
+}
+
+// Apply updates the line with [ins], returned by [Line.IdentifyContext]
+// on the same (unmodified) line, and returns [Insertion.Action].
+func (line *Line) Apply(ins Insertion) RecordAction {
+	if ins.action == Rejected {
+		return ins.action
+	}
 
-	wholeSymbol, _, lastStroke := rec.footprints()
+	wholeSymbol, _, lastStroke := ins.rec.footprints()
 
-	if isCompound {
+	if ins.isCompound {
 		// if a compound symbol is matched, simply update the last block
-		gr := grapheme{Char: r, Symbol: wholeSymbol}
+		gr := grapheme{Char: ins.r, Symbol: wholeSymbol}
 		*line.cursor = newBlock(gr)
 	} else {
-		gr := grapheme{Char: r, Symbol: sy.Footprint{Strokes: []sy.Stroke{lastStroke}}}
+		gr := grapheme{Char: ins.r, Symbol: sy.Footprint{Strokes: []sy.Stroke{lastStroke}}}
 		// add a new block
-		node.insertAt(newBlock(gr), insertPos)
+		ins.node.insertAt(newBlock(gr), ins.insertPos)
 		// .. and save the 'cursor' location
-		line.cursor = &node.blocks[insertPos]
+		line.cursor = &ins.node.blocks[ins.insertPos]
 	}
 
-	fmt.Printf("root : %p ; tree : %v\n", &line.root, line.root)
-
-	return action
+	return ins.action
 }
 
 func newBlock(gr grapheme) block {
@@ -1,11 +1,14 @@
 package layout
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"testing"
 
 	"github.com/benoitkugler/pen2latex/symbols"
 	sy "github.com/benoitkugler/pen2latex/symbols"
+	tu "github.com/benoitkugler/pen2latex/testutils"
 )
 
 func rect(xLeft, xRight, yTop, yBottom float32) symbols.Rect {
@@ -55,3 +58,33 @@ func TestInsertIndex(t *testing.T) {
 	}
 	fmt.Println(indexInsertRectBetweenArea(glyph, candidates))
 }
+
+func TestInsertContext(t *testing.T) {
+	store := sy.NewStore(map[rune]sy.Symbol{
+		'-': {{{X: 0, Y: 20}, {X: 10, Y: 20}, {X: 20, Y: 20}}},
+		'|': {{{X: 10, Y: 0}, {X: 10, Y: 10}, {X: 10, Y: 20}}},
+	})
+	recs := []Record{
+		{{{X: 10, Y: 10}, {X: 10, Y: 30}, {X: 10, Y: 50}}},
+		{{{X: 40, Y: 30}, {X: 55, Y: 30}, {X: 70, Y: 30}}},
+	}
+
+	line1, line2 := NewLine(sy.Rect{LR: sy.Pos{X: 200, Y: 100}}), NewLine(sy.Rect{LR: sy.Pos{X: 200, Y: 100}})
+	for _, rec := range recs {
+		exp := line1.Insert(rec, &store)
+		ins, err := line2.IdentifyContext(context.Background(), rec, &store)
+		tu.AssertNoErr(t, err)
+		tu.AssertEqual(t, ins.Action(), exp)
+		tu.AssertEqual(t, line2.Apply(ins), exp)
+	}
+	tu.AssertEqual(t, line2.LaTeX(), line1.LaTeX())
+	latex := line1.LaTeX()
+	tu.AssertEqual(t, len(latex), 2)
+
+	// cancelled : the line is not modified
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := line2.IdentifyContext(ctx, recs[0], &store)
+	tu.Assert(t, errors.Is(err, context.Canceled))
+	tu.AssertEqual(t, line2.LaTeX(), latex)
+}
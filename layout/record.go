@@ -1,14 +1,14 @@
 package layout
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	sy "github.com/benoitkugler/pen2latex/symbols"
 )
 
-const debugMode = true
-
 type Fl = sy.Fl
 
 // Record stores the raw user input
@@ -26,6 +26,21 @@ func (re Record) String() string {
 	}`, builder.String())
 }
 
+// LabelledRecord is a [Record] with the rune it should be recognized as.
+type LabelledRecord struct {
+	R      rune
+	Record Record
+}
+
+// TuneBezierWeights calls [sy.TuneBezierWeights] on a corpus of records.
+func TuneBezierWeights(corpus []LabelledRecord, rounds int) (sy.BezierWeights, Fl) {
+	symbols := make([]sy.LabelledSymbol, len(corpus))
+	for i, sample := range corpus {
+		symbols[i] = sy.LabelledSymbol{R: sample.R, Symbol: sy.Symbol(sample.Record)}
+	}
+	return sy.TuneBezierWeights(symbols, rounds)
+}
+
 // split returns the symbol drawn before the last connex shape and
 // the last connex compoment drawn
 func (rec Record) split() (sy.Symbol, sy.Shape) {
@@ -61,6 +76,7 @@ const (
 	KeepAll   RecordAction = iota // the whole symbol has been used, and may still be needed
 	KeepLast                      // only the last stroke has been used, but this stroke may be part of a symbol
 	RemoveAll                     // only the last stroke has been used, and we are certain no more strokes may come
+	Rejected                      // no rune has been matched with enough confidence : the record is kept and the user should be asked
 )
 
 func (c RecordAction) String() string {
@@ -71,20 +87,135 @@ func (c RecordAction) String() string {
 		return "KeepLast"
 	case RemoveAll:
 		return "RemoveAll"
+	case Rejected:
+		return "Rejected"
 	default:
 		panic("exhaustive switch")
 	}
 }
 
+// Unknown is the rune returned by [Record.Identify] when the input
+// does not match any symbol of the store with enough confidence.
+const Unknown rune = 0
+
 // Identify returns the rune found, the action to perform on the
-// recorder, and a whether or not the symbol is compound
+// recorder, and a whether or not the symbol is compound.
+// The symbols with variable size are matched against [sy.DefaultTemplates],
+// which are only used when the samples of the store do not match well
+// (see [templateConfidence]).
+//
+// If the store rejects the input (see [sy.Store.Rejection]), [Unknown] and [Rejected]
+// are returned.
 func (rec Record) Identify(store *sy.Store, context sy.HeightGrid) (rune, RecordAction, bool) {
+	return rec.identifyTrace(nil, store, context, nil)
+}
+
+// IdentifyTrace is the same as [Record.Identify], but also returns a report
+// of the decisions taken and of the lookups performed.
+func (rec Record) IdentifyTrace(store *sy.Store, context sy.HeightGrid) (rune, RecordAction, bool, IdentifyTrace) {
+	trace := new(IdentifyTrace)
+	r, action, isCompound := rec.identifyTrace(nil, store, context, trace)
+	return r, action, isCompound, *trace
+}
+
+// IdentifyTraceContext is the same as [Record.IdentifyTrace], but uses
+// [sy.Store.LookupTraceContext], so that the work stops early when [ctx]
+// is cancelled (for instance by a newer stroke), returning [ctx.Err()].
+func (rec Record) IdentifyTraceContext(ctx context.Context, store *sy.Store, grid sy.HeightGrid) (rune, RecordAction, bool, IdentifyTrace, error) {
+	trace := new(IdentifyTrace)
+	r, action, isCompound := rec.identifyTrace(ctx, store, grid, trace)
+	if err := ctx.Err(); err != nil {
+		return Unknown, Rejected, false, IdentifyTrace{}, err
+	}
+	return r, action, isCompound, *trace, nil
+}
+
+// identifyTrace fills [trace] if it is not nil, using
+// the concurrent lookups if [ctx] is not nil (the result is then
+// meaningless if [ctx] is cancelled)
+func (rec Record) identifyTrace(ctx context.Context, store *sy.Store, context sy.HeightGrid, trace *IdentifyTrace) (rune, RecordAction, bool) {
+	r, action, isCompound := rec.identify(ctx, store, context, trace)
+	if r == Unknown {
+		action, isCompound = Rejected, false
+	}
+	if trace != nil {
+		trace.R, trace.Action, trace.IsCompound = r, action, isCompound
+	}
+	return r, action, isCompound
+}
+
+func (rec Record) identify(ctx context.Context, store *sy.Store, context sy.HeightGrid, trace *IdentifyTrace) (rune, RecordAction, bool) {
 	wholeFootprint, previous, last := rec.footprints()
 	previousFooprint, lastFootprint := sy.Footprint{Strokes: previous}, sy.Footprint{Strokes: []sy.Stroke{last}}
 
-	// start with special cases
-	if last.IsSqrt() {
-		return '\u221A', RemoveAll, false // √
+	// [input] is the lookup giving the result, if any
+	branch := func(b IdentifyBranch, input LookupInput) {
+		if trace != nil {
+			trace.Branch, trace.Input = b, input
+		}
+	}
+	// each input is looked up at most once
+	type lookupResult struct {
+		r            rune
+		d            Fl
+		isCompatible bool
+	}
+	lookups := map[LookupInput]lookupResult{}
+	lookup := func(input LookupInput, fp sy.Footprint) (rune, Fl, bool) {
+		if res, has := lookups[input]; has {
+			return res.r, res.d, res.isCompatible
+		}
+		var (
+			res lookupResult
+			lt  sy.Trace
+		)
+		// a cancellation is reported by the callers using [ctx]
+		switch {
+		case ctx != nil && trace == nil:
+			res.r, res.d, res.isCompatible, _ = store.LookupContext(ctx, fp, context)
+		case ctx != nil:
+			res.r, res.d, res.isCompatible, lt, _ = store.LookupTraceContext(ctx, fp, context)
+		case trace == nil:
+			res.r, res.d, res.isCompatible = store.Lookup(fp, context)
+		default:
+			res.r, res.d, res.isCompatible, lt = store.LookupTrace(fp, context)
+		}
+		if trace != nil {
+			trace.Lookups = append(trace.Lookups, LookupTrace{Input: input, Trace: lt})
+		}
+		lookups[input] = res
+		return res.r, res.d, res.isCompatible
+	}
+	// matchTemplate returns the template matching [fp], unless
+	// the store has a good match for another rune
+	matchTemplate := func(input LookupInput, fp sy.Footprint) (rune, bool) {
+		r, ok := sy.DefaultTemplates.Match(fp, context)
+		if !ok {
+			return 0, false
+		}
+		rStore, d, _ := lookup(input, fp)
+		if rStore != Unknown && rStore != r && store.Confidence(d) >= templateConfidence {
+			return 0, false
+		}
+		return r, true
+	}
+
+	// start with the symbols with variable size, described by templates
+	if len(rec) > 1 {
+		if r, ok := matchTemplate(WholeInput, wholeFootprint); ok {
+			branch(TemplateWholeBranch, "")
+			if sy.DefaultTemplates.IsPrefix(wholeFootprint, context) {
+				return r, KeepAll, true
+			}
+			return r, RemoveAll, true
+		}
+	}
+	if r, ok := matchTemplate(LastInput, lastFootprint); ok {
+		branch(TemplateBranch, "")
+		if sy.DefaultTemplates.IsPrefix(lastFootprint, context) {
+			return r, KeepLast, false
+		}
+		return r, RemoveAll, false
 	}
 
 	// special case for points
@@ -92,26 +223,19 @@ func (rec Record) Identify(store *sy.Store, context sy.HeightGrid) (rune, Record
 		// decide to match the whole symbol base on the X value
 		bbox := previousFooprint.BoundingBox()
 		if bbox.LR.X+2 >= point.X {
-
-			if debugMode {
-				fmt.Println("Identify record : point -> whole symbol matched")
-			}
-
-			r, _, _ := store.Lookup(wholeFootprint, context)
+			branch(PointWholeBranch, WholeInput)
+			r, _, _ := lookup(WholeInput, wholeFootprint)
 			return r, KeepAll, true
 		}
 
 		// standalone point
+		branch(PointBranch, "")
 		return '.', RemoveAll, false
 	}
 
 	if toMatch, ok := rec.isSeparated(); ok { // easy case : only use the last stroke
-
-		if debugMode {
-			fmt.Println("Identify record : isSeparated -> last stroke matched")
-		}
-
-		r, _, isCompatible := store.Lookup(toMatch.Footprint(), context)
+		branch(SeparatedBranch, LastInput)
+		r, _, isCompatible := lookup(LastInput, toMatch.Footprint())
 		if isCompatible {
 			return r, KeepLast, false
 		}
@@ -120,12 +244,19 @@ func (rec Record) Identify(store *sy.Store, context sy.HeightGrid) (rune, Record
 
 	// here, len(rec) > 1
 	if isMerged(previous, last) {
-
-		if debugMode {
-			fmt.Println("Identify record : isMerged -> whole symbol matched")
+		branch(MergedBranch, WholeInput)
+		r, _, isCompatible := lookup(WholeInput, wholeFootprint)
+		if isCompatible {
+			return r, KeepAll, true
 		}
+		return r, RemoveAll, true
+	}
 
-		r, _, isCompatible := store.Lookup(wholeFootprint, context)
+	// the strokes of distinct symbols do not cross :
+	// x is one symbol, but )( are two
+	if crossesPrevious(wholeFootprint, previousFooprint, lastFootprint) {
+		branch(CrossingBranch, WholeInput)
+		r, _, isCompatible := lookup(WholeInput, wholeFootprint)
 		if isCompatible {
 			return r, KeepAll, true
 		}
@@ -138,21 +269,15 @@ func (rec Record) Identify(store *sy.Store, context sy.HeightGrid) (rune, Record
 	// to disambiguate, we perform two lookups and compare errors
 
 	// lookup with the whole symbol
-	rWhole, errWhole, isWholeCompatible := store.Lookup(wholeFootprint, context)
+	rWhole, errWhole, isWholeCompatible := lookup(WholeInput, wholeFootprint)
 
 	// lookup with separated symbol
-	_, errPrevious, _ := store.Lookup(previousFooprint, context)
-	rLast, errLast, isLastCompatible := store.Lookup(lastFootprint, context)
-
-	// fmt.Println(errPrevious, errLast, errWhole)
+	_, errPrevious, _ := lookup(PreviousInput, previousFooprint)
+	rLast, errLast, isLastCompatible := lookup(LastInput, lastFootprint)
 
 	// it always easier to match separate parts : compense a bit
 	if 2*sy.Max(errPrevious, errLast) < errWhole {
-
-		if debugMode {
-			fmt.Println("Identify record : after lookup -> last stroke matched")
-		}
-
+		branch(LastLookupBranch, LastInput)
 		if isWholeCompatible { // even is we prefer the last for now, keep the previous strokes
 			return rLast, KeepAll, false
 		}
@@ -162,36 +287,41 @@ func (rec Record) Identify(store *sy.Store, context sy.HeightGrid) (rune, Record
 		return rLast, RemoveAll, false
 	}
 
-	if debugMode {
-		fmt.Println("Identify record : after lookup -> whole stroke matched")
-	}
-
+	branch(WholeLookupBranch, WholeInput)
 	if isWholeCompatible {
 		return rWhole, KeepAll, true
 	}
 	return rWhole, RemoveAll, true
 }
 
+// templateConfidence is the confidence (see [sy.Store.Confidence]) above which
+// the samples of the store are preferred over the templates.
+// Note that an uncalibrated store always has a confidence of 1, so that the templates
+// are only used when it has no compatible entry.
+const templateConfidence = 0.5
+
 // return true if the last stroke has one intersection
 // with the others
 func isMerged(previous []sy.Stroke, last sy.Stroke) bool {
 	if len(last.Curves) != 1 {
 		return false
 	}
-	seg := last.Curves[0]
-	if !seg.IsRoughlyLinear() {
+	if !last.Curves[0].IsRoughlyLinear() {
 		return false
 	}
 	for _, stroke := range previous {
-		for _, cu := range stroke.Curves {
-			if cu.HasIntersection(seg) {
-				return true
-			}
+		if len(stroke.Intersections(last)) != 0 {
+			return true
 		}
 	}
 	return false
 }
 
+// crossesPrevious returns true if the last stroke crosses the previous ones
+func crossesPrevious(whole, previous, last sy.Footprint) bool {
+	return whole.Crossings() > previous.Crossings()+last.Crossings()
+}
+
 // isSeparated returns true if we are certain only the last
 // stroke should be used when matching runes
 // is always returns true if len(rec) == 1
@@ -220,28 +350,59 @@ type Recorder struct {
 	// Record is the accumulated Record
 	Record Record
 
+	// Info is aligned with [Record], and stores the timestamps and pressure
+	// of the points added with [Recorder.AddTimedPoint].
+	// Shapes with at least one point added with [Recorder.AddToShape] have
+	// an empty info.
+	Info sy.SymbolInfo
+
 	currentShape sy.Shape
+	currentInfo  sy.ShapeInfo
 	inShape      bool
+
+	origin    time.Duration // time of the first point of [Record]
+	hasOrigin bool
 }
 
 func (rec *Recorder) DropButLast() {
 	if len(rec.Record) == 0 {
 		return
 	}
+	// [Record] may have been modified directly
+	isAligned := len(rec.Info) == len(rec.Record)
 	rec.Record = Record{rec.Record[len(rec.Record)-1]}
+	if !isAligned {
+		rec.Info = nil
+		return
+	}
+
+	// times are relative to the first point of the record
+	last := rec.Info[len(rec.Info)-1]
+	rec.Info = sy.SymbolInfo{last}
+	if len(last) != 0 {
+		start := last[0].Time
+		for i := range last {
+			last[i].Time -= start
+		}
+		rec.origin += time.Duration(start * Fl(time.Millisecond))
+	}
 }
 
 // Reset clears the current state of the `Recorder`
 func (rec *Recorder) Reset() {
 	rec.inShape = false
 	rec.Record = nil
+	rec.Info = nil
 	rec.currentShape = nil
+	rec.currentInfo = nil
+	rec.hasOrigin = false
 }
 
 // StartShape starts the recording of a new connex shape.
 func (rec *Recorder) StartShape() {
 	rec.inShape = true
 	rec.currentShape = sy.Shape{}
+	rec.currentInfo = sy.ShapeInfo{}
 }
 
 // EndShape ends the recording of the current connex shape.
@@ -250,8 +411,14 @@ func (rec *Recorder) EndShape() {
 	rec.inShape = false
 	if current := rec.currentShape; len(current) != 0 {
 		rec.Record = append(rec.Record, current)
+		info := rec.currentInfo
+		if len(info) != len(current) { // some points have no info
+			info = nil
+		}
+		rec.Info = append(rec.Info, info)
 	}
 	rec.currentShape = nil
+	rec.currentInfo = nil
 }
 
 // AddToShape adds a point to the shape, if one has started
@@ -260,3 +427,20 @@ func (rec *Recorder) AddToShape(pos sy.Pos) {
 		rec.currentShape = append(rec.currentShape, pos)
 	}
 }
+
+// AddTimedPoint adds a point to the shape, if one has started,
+// with the time [t] of the event (relative to an arbitrary origin)
+// and the pen [pressure] (0 if not supported).
+func (rec *Recorder) AddTimedPoint(pos sy.Pos, t time.Duration, pressure Fl) {
+	if !rec.inShape {
+		return
+	}
+	if !rec.hasOrigin {
+		rec.origin, rec.hasOrigin = t, true
+	}
+	rec.currentShape = append(rec.currentShape, pos)
+	rec.currentInfo = append(rec.currentInfo, sy.PointInfo{
+		Time:     Fl(t-rec.origin) / Fl(time.Millisecond),
+		Pressure: pressure,
+	})
+}
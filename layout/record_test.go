@@ -1,8 +1,14 @@
 package layout
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	sy "github.com/benoitkugler/pen2latex/symbols"
 	tu "github.com/benoitkugler/pen2latex/testutils"
@@ -78,3 +84,193 @@ func Test_isMerged(t *testing.T) {
 	_, previous, last := input.footprints()
 	tu.Assert(t, !isMerged(previous, last))
 }
+
+func Test_crossesPrevious(t *testing.T) {
+	for _, test := range []struct {
+		rec      Record
+		expected bool
+	}{
+		{Record{{{X: 0, Y: 0}, {X: 10, Y: 10}, {X: 20, Y: 20}}, {{X: 20, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 20}}}, true}, // x
+		{Record{{{X: 0, Y: 0}, {X: 8, Y: 10}, {X: 0, Y: 20}}, {{X: 20, Y: 0}, {X: 12, Y: 10}, {X: 20, Y: 20}}}, false}, // )(
+	} {
+		whole, previous, last := test.rec.footprints()
+		got := crossesPrevious(whole, sy.Footprint{Strokes: previous}, sy.Footprint{Strokes: []sy.Stroke{last}})
+		tu.AssertEqual(t, got, test.expected)
+	}
+}
+
+// generateCircle returns a closed circle, with [nbPoints]+1 points
+func generateCircle(center sy.Pos, radius Fl, nbPoints int) sy.Shape {
+	var out sy.Shape
+	for i := 0; i <= nbPoints; i++ {
+		theta := 2 * math.Pi * float64(i) / float64(nbPoints)
+		out = append(out, center.Add(sy.Pos{X: Fl(math.Cos(theta)), Y: Fl(math.Sin(theta))}.ScaleTo(radius)))
+	}
+	return out
+}
+
+func TestIdentifyRejected(t *testing.T) {
+	store := sy.NewStore(map[rune]sy.Symbol{'a': {generateCircle(sy.Pos{X: 20, Y: 20}, 10, 30)}})
+	rec := Record{generateCircle(sy.Pos{X: 50, Y: 50}, 15, 30)}
+
+	r, action, _ := rec.Identify(&store, sy.HeightGrid{})
+	tu.AssertEqual(t, r, 'a')
+	tu.Assert(t, action != Rejected)
+
+	// reject everything but perfect matches
+	store.Calibration = sy.Calibration{Scale: 1e-6}
+	store.Rejection = 0.5
+
+	r, action, _ = rec.Identify(&store, sy.HeightGrid{})
+	tu.AssertEqual(t, r, Unknown)
+	tu.AssertEqual(t, action, Rejected)
+
+	line := NewLine(sy.Rect{LR: sy.Pos{X: 200, Y: 100}})
+	action = line.Insert(rec, &store)
+	tu.AssertEqual(t, action, Rejected)
+	tu.AssertEqual(t, line.LaTeX(), "")
+}
+
+func TestIdentifyTrace(t *testing.T) {
+	store := sy.NewStore(map[rune]sy.Symbol{'a': {generateCircle(sy.Pos{X: 20, Y: 20}, 10, 30)}})
+	rec := Record{generateCircle(sy.Pos{X: 50, Y: 50}, 15, 30)}
+
+	r, action, isCompound, trace := rec.IdentifyTrace(&store, sy.HeightGrid{})
+	r2, action2, isCompound2 := rec.Identify(&store, sy.HeightGrid{})
+	tu.AssertEqual(t, r, r2)
+	tu.AssertEqual(t, action, action2)
+	tu.AssertEqual(t, isCompound, isCompound2)
+
+	tu.AssertEqual(t, trace.Branch, SeparatedBranch)
+	tu.AssertEqual(t, trace.R, 'a')
+	tu.AssertEqual(t, trace.Action, action)
+	tu.AssertEqual(t, len(trace.Lookups), 1)
+	tu.AssertEqual(t, trace.Lookups[0].Input, LastInput)
+	tu.AssertEqual(t, trace.Lookups[0].R, 'a')
+	tu.AssertEqual(t, len(trace.Lookups[0].Entries), 1)
+	tu.AssertEqual(t, trace.Input, LastInput)
+	tu.AssertEqual(t, len(trace.Candidates()), 1)
+	tu.AssertEqual(t, trace.Candidates()[0].R, 'a')
+
+	// the concurrent version has the same result
+	r3, action3, isCompound3, trace3, err := rec.IdentifyTraceContext(context.Background(), &store, sy.HeightGrid{})
+	tu.AssertNoErr(t, err)
+	tu.AssertEqual(t, r3, r)
+	tu.AssertEqual(t, action3, action)
+	tu.AssertEqual(t, isCompound3, isCompound)
+	tu.AssertEqual(t, trace3, trace)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r3, action3, _, _, err = rec.IdentifyTraceContext(ctx, &store, sy.HeightGrid{})
+	tu.Assert(t, errors.Is(err, context.Canceled))
+	tu.AssertEqual(t, r3, Unknown)
+	tu.AssertEqual(t, action3, Rejected)
+
+	b, err := json.Marshal(trace)
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, strings.Contains(string(b), `"branch":"Separated"`))
+
+	// reject everything but perfect matches
+	store.Calibration = sy.Calibration{Scale: 1e-6}
+	store.Rejection = 0.5
+	line := NewLine(sy.Rect{LR: sy.Pos{X: 200, Y: 100}})
+	action, trace = line.InsertTrace(rec, &store)
+	tu.AssertEqual(t, action, Rejected)
+	tu.AssertEqual(t, trace.Action, Rejected)
+	tu.AssertEqual(t, trace.R, Unknown)
+	tu.AssertEqual(t, len(trace.Lookups), 1)
+}
+
+func TestIdentifyTemplates(t *testing.T) {
+	vertical := func(x Fl) sy.Shape {
+		var out sy.Shape
+		for i := 0; i <= 30; i++ {
+			out = append(out, sy.Pos{X: x, Y: 5 + 3*Fl(i)})
+		}
+		return out
+	}
+	// the vertical line is far from the samples of the store
+	store := sy.NewStore(map[rune]sy.Symbol{'o': {generateCircle(sy.Pos{X: 20, Y: 20}, 10, 30)}})
+	store.Calibration = sy.Calibration{Scale: 1e-6}
+	line := NewLine(sy.Rect{LR: sy.Pos{X: 200, Y: 100}})
+
+	action, trace := line.InsertTrace(Record{vertical(20)}, &store)
+	tu.AssertEqual(t, trace.Branch, TemplateBranch)
+	tu.AssertEqual(t, trace.R, '|')
+	tu.AssertEqual(t, action, KeepLast) // may be the start of ‖
+	tu.AssertEqual(t, line.LaTeX(), "|")
+
+	action, trace = line.InsertTrace(Record{vertical(20), vertical(26)}, &store)
+	tu.AssertEqual(t, trace.Branch, TemplateWholeBranch)
+	tu.AssertEqual(t, trace.R, '‖')
+	tu.AssertEqual(t, action, RemoveAll)
+	tu.AssertEqual(t, line.LaTeX(), "‖")
+
+	// the samples of the user are preferred over the templates
+	store = sy.NewStore(map[rune]sy.Symbol{'a': {vertical(0)}})
+	grid := NewLine(sy.Rect{LR: sy.Pos{X: 200, Y: 100}}).root.grid()
+	r, _, _, trace := Record{vertical(20)}.IdentifyTrace(&store, grid)
+	tu.AssertEqual(t, r, 'a')
+	tu.AssertEqual(t, len(trace.Lookups), 1) // the lookup is not repeated
+
+	// without size reference, the store is used
+	r, _, _ = Record{vertical(20)}.Identify(&store, sy.HeightGrid{})
+	tu.AssertEqual(t, r, 'a')
+}
+
+func TestRecorderInfo(t *testing.T) {
+	var rec Recorder
+	rec.StartShape()
+	rec.AddTimedPoint(sy.Pos{X: 0, Y: 0}, 2*time.Second, 0.5)
+	rec.AddTimedPoint(sy.Pos{X: 1, Y: 0}, 2*time.Second+10*time.Millisecond, 0.6)
+	rec.EndShape()
+	rec.StartShape()
+	rec.AddTimedPoint(sy.Pos{X: 0, Y: 5}, 3*time.Second, 0.5)
+	rec.EndShape()
+
+	tu.AssertEqual(t, rec.Info, sy.SymbolInfo{
+		{{Time: 0, Pressure: 0.5}, {Time: 10, Pressure: 0.6}},
+		{{Time: 1000, Pressure: 0.5}},
+	})
+
+	// times are relative to the first point of the record
+	rec.DropButLast()
+	tu.AssertEqual(t, rec.Info, sy.SymbolInfo{{{Time: 0, Pressure: 0.5}}})
+	rec.StartShape()
+	rec.AddTimedPoint(sy.Pos{X: 0, Y: 5}, 3*time.Second+20*time.Millisecond, 0.5)
+	rec.EndShape()
+	tu.AssertEqual(t, rec.Info[1], sy.ShapeInfo{{Time: 20, Pressure: 0.5}})
+
+	// points without info
+	rec.Reset()
+	rec.StartShape()
+	rec.AddTimedPoint(sy.Pos{X: 0, Y: 0}, time.Second, 0)
+	rec.AddToShape(sy.Pos{X: 1, Y: 0})
+	rec.EndShape()
+	tu.AssertEqual(t, len(rec.Record), 1)
+	tu.AssertEqual(t, len(rec.Info), 1)
+	tu.AssertEqual(t, len(rec.Info[0]), 0)
+
+	// the record may be set directly
+	rec.Record = Record{{{X: 0, Y: 0}}, {{X: 1, Y: 0}}}
+	rec.DropButLast()
+	tu.AssertEqual(t, len(rec.Record), 1)
+	tu.AssertEqual(t, len(rec.Info), 0)
+}
+
+func TestTuneBezierWeights(t *testing.T) {
+	line := func(length Fl) sy.Shape {
+		return sy.Shape{{X: 10, Y: 10}, {X: 10, Y: 10 + length/2}, {X: 10, Y: 10 + length}}
+	}
+	corpus := []LabelledRecord{
+		{'o', Record{generateCircle(sy.Pos{X: 50, Y: 50}, 10, 40)}}, {'o', Record{generateCircle(sy.Pos{X: 50, Y: 50}, 20, 40)}},
+		{'l', Record{line(20)}}, {'l', Record{line(40)}},
+	}
+	w, acc := TuneBezierWeights(corpus, 1)
+	tu.AssertEqual(t, acc, Fl(1))
+	tu.AssertEqual(t, w, sy.DefaultBezierWeights)
+
+	_, acc = TuneBezierWeights(corpus[:1], 1)
+	tu.AssertEqual(t, acc, Fl(-1))
+}
@@ -0,0 +1,95 @@
+package layout
+
+import (
+	sy "github.com/benoitkugler/pen2latex/symbols"
+)
+
+// IdentifyBranch is the heuristic used by [Record.Identify]
+// to decide which strokes should be matched.
+type IdentifyBranch uint8
+
+const (
+	TemplateWholeBranch IdentifyBranch = iota // the whole symbol matches a template (see [sy.Templates])
+	TemplateBranch                            // the last stroke matches a template
+	PointWholeBranch                          // the last stroke is a point, attached to the previous strokes
+	PointBranch                               // the last stroke is a standalone point
+	SeparatedBranch                           // the last stroke is far from the previous ones
+	MergedBranch                              // the last stroke is a line crossing the previous ones
+	CrossingBranch                            // the last stroke crosses the previous ones
+	LastLookupBranch                          // the lookup errors favor the last stroke alone
+	WholeLookupBranch                         // the lookup errors favor the whole symbol
+)
+
+func (b IdentifyBranch) String() string {
+	switch b {
+	case TemplateWholeBranch:
+		return "TemplateWhole"
+	case TemplateBranch:
+		return "Template"
+	case PointWholeBranch:
+		return "PointWhole"
+	case PointBranch:
+		return "Point"
+	case SeparatedBranch:
+		return "Separated"
+	case MergedBranch:
+		return "Merged"
+	case CrossingBranch:
+		return "Crossing"
+	case LastLookupBranch:
+		return "LastLookup"
+	case WholeLookupBranch:
+		return "WholeLookup"
+	default:
+		panic("exhaustive switch")
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler, so that
+// the branch is readable in JSON traces.
+func (b IdentifyBranch) MarshalText() ([]byte, error) { return []byte(b.String()), nil }
+
+// MarshalText implements encoding.TextMarshaler, so that
+// the action is readable in JSON traces.
+func (c RecordAction) MarshalText() ([]byte, error) { return []byte(c.String()), nil }
+
+// LookupInput identifies the strokes of a [Record] used in a lookup.
+type LookupInput string
+
+const (
+	WholeInput    LookupInput = "whole"    // all the strokes
+	PreviousInput LookupInput = "previous" // all the strokes but the last
+	LastInput     LookupInput = "last"     // the last stroke
+)
+
+// LookupTrace is one of the lookups performed by [Record.IdentifyTrace].
+type LookupTrace struct {
+	Input LookupInput `json:"input"`
+	sy.Trace
+}
+
+// IdentifyTrace is the report returned by [Record.IdentifyTrace].
+// It may be encoded to JSON.
+type IdentifyTrace struct {
+	Branch  IdentifyBranch `json:"branch"`
+	Lookups []LookupTrace  `json:"lookups"` // in the order they are performed
+	// Input is the lookup giving the result, or an empty string
+	// if the rune is not matched against the store (templates, standalone points)
+	Input LookupInput `json:"input,omitempty"`
+
+	// the result, as returned by [Record.Identify]
+	R          rune         `json:"rune"`
+	Action     RecordAction `json:"action"`
+	IsCompound bool         `json:"isCompound"`
+}
+
+// Candidates returns the ranked candidates of the lookup
+// giving the result (see [IdentifyTrace.Input]), or nil.
+func (it IdentifyTrace) Candidates() []sy.Candidate {
+	for _, lookup := range it.Lookups {
+		if lookup.Input == it.Input {
+			return lookup.Candidates
+		}
+	}
+	return nil
+}
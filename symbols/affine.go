@@ -0,0 +1,163 @@
+package symbols
+
+import (
+	"math"
+	"sort"
+)
+
+// This file implements general affine transformations, used
+// to normalize the slant and the orientation of the handwriting.
+
+// Affine encodes a general affine transformation,
+// including shear and rotation :
+//
+//	V = | A  B | U  + | tx |
+//		| C  D |	  | ty |
+type Affine struct {
+	A, B, C, D  Fl
+	Translation Pos
+}
+
+// AffineId is the identity transformation.
+var AffineId = Affine{A: 1, D: 1}
+
+// Affine returns [tr] as a general transformation.
+func (tr Trans) Affine() Affine {
+	return Affine{A: tr.Scale, D: tr.Scale, Translation: tr.Translation}
+}
+
+// Shear returns the horizontal shear moving the points
+// by tan(angle) * y, with [angle] in degrees.
+// Since the y axis points down, Shear(s) removes the slant s
+// returned by [EstimateSlant], and Shear(-s) adds it.
+func Shear(angle Fl) Affine {
+	k := Fl(math.Tan(float64(angle) * math.Pi / 180))
+	return Affine{A: 1, B: k, D: 1}
+}
+
+// Rotation returns the rotation of [angle] (in degrees) around [center].
+// Since the y axis points down, positive angles are clockwise.
+func Rotation(angle Fl, center Pos) Affine {
+	sin, cos := math.Sincos(float64(angle) * math.Pi / 180)
+	s, c := Fl(sin), Fl(cos)
+	rot := Affine{A: c, B: -s, C: s, D: c}
+	// rotate around the origin, then move back the center
+	rot.Translation = center.Sub(rot.Apply(center))
+	return rot
+}
+
+func (af Affine) Apply(p Pos) Pos {
+	return Pos{
+		X: af.A*p.X + af.B*p.Y + af.Translation.X,
+		Y: af.C*p.X + af.D*p.Y + af.Translation.Y,
+	}
+}
+
+// Then returns the transformation applying [af], then [other].
+func (af Affine) Then(other Affine) Affine {
+	return Affine{
+		A:           other.A*af.A + other.B*af.C,
+		B:           other.A*af.B + other.B*af.D,
+		C:           other.C*af.A + other.D*af.C,
+		D:           other.C*af.B + other.D*af.D,
+		Translation: other.Apply(af.Translation),
+	}
+}
+
+// Transform applies [af] to the control points,
+// which is the same as transforming the curve.
+func (b Bezier) Transform(af Affine) Bezier {
+	return Bezier{af.Apply(b.P0), af.Apply(b.P1), af.Apply(b.P2), af.Apply(b.P3)}
+}
+
+// Transform returns a new shape.
+func (sh Shape) Transform(af Affine) Shape {
+	out := make(Shape, len(sh))
+	for i, p := range sh {
+		out[i] = af.Apply(p)
+	}
+	return out
+}
+
+// Transform returns a new symbol.
+func (sy Symbol) Transform(af Affine) Symbol {
+	out := make(Symbol, len(sy))
+	for i, shape := range sy {
+		out[i] = shape.Transform(af)
+	}
+	return out
+}
+
+// transform returns a new stroke.
+// Contrary to [Trans], [af] does not preserve the relative lengths,
+// which are thus computed again.
+func (fp Stroke) transform(af Affine) Stroke {
+	out := Stroke{Curves: make([]Bezier, len(fp.Curves))}
+	for i, c := range fp.Curves {
+		out.Curves[i] = c.Transform(af)
+	}
+	out.inferArcLengths()
+	return out
+}
+
+// Transform returns a new footprint.
+func (sf Footprint) Transform(af Affine) Footprint {
+	out := Footprint{Strokes: make([]Stroke, len(sf.Strokes))}
+	for i, st := range sf.Strokes {
+		out.Strokes[i] = st.transform(af)
+	}
+	return out
+}
+
+// maxSlant is the maximum angle with the vertical axis
+// of the parts used to estimate the slant
+const maxSlant = 45
+
+// EstimateSlant returns the dominant slant of the given footprints, in degrees,
+// positive when the writing leans to the right.
+// It is the median of the direction of the (roughly) vertical parts
+// of the strokes, weighted by their length. Since some symbols have slanted parts
+// (like x or /), it is meant to be used on the samples of a writer, not on one symbol.
+// It returns 0 if there is no vertical part.
+func EstimateSlant(fps ...Footprint) Fl {
+	type part struct{ slant, length Fl }
+	var (
+		parts       []part
+		totalLength Fl
+	)
+	for _, fp := range fps {
+		for _, st := range fp.Strokes {
+			for _, cu := range st.Curves {
+				points := append(cu.toPoints(), cu.P3)
+				for i := 1; i < len(points); i++ {
+					u := points[i].Sub(points[i-1])
+					if u.Y == 0 {
+						continue
+					}
+					// angle with the vertical axis, whatever the drawing direction
+					slant := Fl(math.Atan(float64(-u.X/u.Y)) * 180 / math.Pi)
+					if abs(slant) > maxSlant {
+						continue
+					}
+					length := u.Norm()
+					parts = append(parts, part{slant, length})
+					totalLength += length
+				}
+			}
+		}
+	}
+	if len(parts) == 0 {
+		return 0
+	}
+
+	// weighted median
+	sort.Slice(parts, func(i, j int) bool { return parts[i].slant < parts[j].slant })
+	var cumulated Fl
+	for _, p := range parts {
+		cumulated += p.length
+		if cumulated >= totalLength/2 {
+			return p.slant
+		}
+	}
+	return parts[len(parts)-1].slant
+}
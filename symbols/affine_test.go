@@ -0,0 +1,146 @@
+package symbols
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	tu "github.com/benoitkugler/pen2latex/testutils"
+)
+
+func TestAffine(t *testing.T) {
+	p := Pos{3, -4}
+
+	tr := Trans{Scale: 2, Translation: Pos{1, 1}}
+	tu.Assert(t, almostEqualPos(tr.Affine().Apply(p), tr.Apply(p)))
+	tu.Assert(t, almostEqualPos(AffineId.Apply(p), p))
+
+	// rotations preserve the distance to the center
+	center := Pos{1, 2}
+	rot := Rotation(30, center)
+	tu.Assert(t, almostEqualPos(rot.Apply(center), center))
+	tu.Assert(t, almostEqual(distP(rot.Apply(p), center), distP(p, center)))
+	tu.Assert(t, almostEqualPos(Rotation(90, Pos{}).Apply(Pos{1, 0}), Pos{0, 1}))
+	tu.Assert(t, almostEqualPos(rot.Then(Rotation(-30, center)).Apply(p), p))
+
+	// shears preserve the y coordinate
+	sh := Shear(45)
+	tu.Assert(t, almostEqualPos(sh.Apply(p), Pos{-1, -4}))
+	tu.Assert(t, almostEqualPos(sh.Then(Shear(-45)).Apply(p), p))
+
+	composed := tr.Affine().Then(rot)
+	tu.Assert(t, almostEqualPos(composed.Apply(p), rot.Apply(tr.Apply(p))))
+}
+
+// slanted returns the samples, written with the given slant
+func slanted(slant Fl) map[rune][]Symbol {
+	out := map[rune][]Symbol{}
+	for _, group := range symbols {
+		r, _ := utf8.DecodeRuneInString(group.description)
+		for _, sy := range group.symbols {
+			out[r] = append(out[r], sy.Transform(Shear(-slant)))
+		}
+	}
+	return out
+}
+
+func TestEstimateSlant(t *testing.T) {
+	tu.AssertEqual(t, EstimateSlant(), Fl(0))
+
+	footprints := func(samples map[rune][]Symbol) (out []Footprint) {
+		for _, sys := range samples {
+			for _, sy := range sys {
+				out = append(out, sy.Footprint())
+			}
+		}
+		return out
+	}
+
+	reference := EstimateSlant(footprints(slanted(0))...)
+	tu.Assert(t, abs(reference) < 5) // the samples are roughly upright
+
+	for _, slant := range []Fl{-15, 10, 20, 30} {
+		estimated := EstimateSlant(footprints(slanted(slant))...)
+		tu.Assert(t, abs(estimated-reference-slant) < 5)
+	}
+}
+
+func TestDeslant(t *testing.T) {
+	// upright samples, and the slanted samples of half of the runes
+	const slant = 30
+	var base, user Store
+	userRunes := map[rune]bool{}
+	for i, group := range symbols {
+		r, _ := utf8.DecodeRuneInString(group.description)
+		base.Add(r, group.symbols[0])
+		if i%2 == 0 {
+			userRunes[r] = true
+		}
+	}
+	for r, sys := range slanted(slant) {
+		if userRunes[r] {
+			for _, sy := range sys {
+				user.Add(r, sy)
+			}
+		}
+	}
+	db := NewLayeredStore(base, Store{}, user)
+
+	accuracy := func() (ok int) {
+		for r, sys := range slanted(slant) {
+			if userRunes[r] {
+				continue
+			}
+			for _, sy := range sys {
+				if got, _, _ := db.Lookup(sy.Footprint(), HeightGrid{}); got == r {
+					ok++
+				}
+			}
+		}
+		return ok
+	}
+
+	withoutDeslant := accuracy()
+	db.Deslant = true
+	withDeslant := accuracy()
+	tu.Assert(t, withDeslant > withoutDeslant)
+}
+
+func TestRotationBounds(t *testing.T) {
+	tu.AssertEqual(t, DefaultRotationBounds.Bound('a'), DefaultRotationBounds.Default)
+	tu.AssertEqual(t, DefaultRotationBounds.Bound('<'), Fl(0))
+
+	var db Store
+	for _, group := range symbols {
+		r, _ := utf8.DecodeRuneInString(group.description)
+		db.Add(r, group.symbols[0])
+	}
+	idx := db.getIndex()
+	for i, entry := range db.Symbols {
+		fp := entry.Footprint
+		box := fp.controlBox()
+		rotated := fp.Transform(Rotation(10, box.UL.Add(box.LR).ScaleTo(0.5)))
+
+		db.Rotation = RotationBounds{}
+		d1 := db.entryDistance(idx, i, db.prepareInput(idx, rotated), false)
+		db.Rotation = DefaultRotationBounds
+		d2 := db.entryDistance(idx, i, db.prepareInput(idx, rotated), false)
+
+		if DefaultRotationBounds.Bound(entry.R) == 0 { // orientation matters
+			tu.AssertEqual(t, d2, d1)
+		} else { // the rotation is compensated
+			tu.Assert(t, d2 <= d1)
+		}
+	}
+
+	// + and × are still distinguished
+	for _, group := range symbols {
+		r, _ := utf8.DecodeRuneInString(group.description)
+		if r != '+' && r != '×' {
+			continue
+		}
+		for _, sy := range group.symbols {
+			got, _, _ := db.Lookup(sy.Footprint(), HeightGrid{})
+			tu.AssertEqual(t, got, r)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package symbols
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// This file implements the stroke-order and stroke-count invariant
+// matching used by [BezierDistance] when [BezierDistance.Unordered] is set :
+// the strokes of the two symbols are partitioned into groups matched together,
+// each group being made of one stroke on one side, and one or two strokes on the other
+// (a stroke drawn with a pen lift, or two strokes drawn at once).
+
+// DefaultAssignmentBudget is the number of partial assignments explored
+// when [BezierDistance.Budget] is zero.
+const DefaultAssignmentBudget = 2000
+
+// maxAssignmentStrokes is the maximum number of strokes supported
+// by the unordered matching (strokes are stored in bit masks)
+const maxAssignmentStrokes = 64
+
+// mergePenalty is applied to the distance of the groups with two strokes,
+// so that a one to one correspondence is preferred
+const mergePenalty = 2
+
+// strokeGroup stores the strokes of U and V matched together, as bit masks
+type strokeGroup struct {
+	u, v uint64
+}
+
+// size returns the number of strokes in the group,
+// counting the two sides with the same weight
+func (g strokeGroup) size() Fl {
+	return Fl(bits.OnesCount64(g.u)+bits.OnesCount64(g.v)) / 2
+}
+
+// assignment stores the state of the search
+type assignment struct {
+	w      BezierWeights
+	U, V   []Stroke // U is already scaled to V, empty strokes are removed
+	groups map[strokeGroup]Fl
+
+	indicesU, indicesV []int // the indices of [U] and [V] in the original footprints
+
+	budget int
+	best   Fl // best total found so far, not normalized
+
+	path, bestPath []strokeGroup // the groups of the current and best assignments
+}
+
+// distanceSymbolsUnordered returns the distance between U and V, after rescaling U to V,
+// using the best correspondence between their strokes found
+// by exploring at most [budget] partial assignments.
+func (w BezierWeights) distanceSymbolsUnordered(U, V Footprint, budget int) Fl {
+	as, ok := w.assignStrokes(U, V, budget)
+	if !ok {
+		return Inf
+	}
+	return as.best / (Fl(len(as.U)+len(as.V)) / 2)
+}
+
+// assignStrokes performs the search of the best correspondence between
+// the strokes of U and V, returning false if the strokes are not supported.
+// Empty strokes are ignored.
+func (w BezierWeights) assignStrokes(U, V Footprint, budget int) (*assignment, bool) {
+	us, indicesU := nonEmptyStrokes(U.Strokes)
+	vs, indicesV := nonEmptyStrokes(V.Strokes)
+	m, n := len(us), len(vs)
+	if m == 0 || n == 0 || m > maxAssignmentStrokes || n > maxAssignmentStrokes {
+		return nil, false
+	}
+	if budget <= 0 {
+		budget = DefaultAssignmentBudget
+	}
+
+	tr := mapFromTo(U.controlBox(), V.controlBox())
+	for i, s := range us {
+		us[i] = s.scale(tr)
+	}
+
+	as := &assignment{
+		w:        w,
+		U:        us,
+		V:        vs,
+		groups:   make(map[strokeGroup]Fl),
+		indicesU: indicesU,
+		indicesV: indicesV,
+		budget:   budget,
+		best:     Inf,
+	}
+	as.search(0, 0, 0)
+	return as, true
+}
+
+// nonEmptyStrokes returns a copy of [strokes] without the empty strokes,
+// and the indices of the strokes kept
+func nonEmptyStrokes(strokes []Stroke) ([]Stroke, []int) {
+	out, indices := make([]Stroke, 0, len(strokes)), make([]int, 0, len(strokes))
+	for i, st := range strokes {
+		if len(st.Curves) != 0 {
+			out = append(out, st)
+			indices = append(indices, i)
+		}
+	}
+	return out, indices
+}
+
+// search completes the partial assignment using
+// the strokes not in [usedU] and [usedV], whose cost is [partial]
+func (as *assignment) search(usedU, usedV uint64, partial Fl) {
+	if as.budget <= 0 {
+		return
+	}
+	as.budget--
+
+	fullU, fullV := uint64(1)<<len(as.U)-1, uint64(1)<<len(as.V)-1
+	if usedV == fullV {
+		if usedU == fullU && partial < as.best {
+			as.best = partial
+			as.bestPath = append(as.bestPath[:0], as.path...)
+		}
+		return
+	}
+
+	// the first free stroke of V must be used
+	j := bits.TrailingZeros64(^usedV)
+	type option struct {
+		group strokeGroup
+		cost  Fl
+	}
+	var options []option
+	add := func(g strokeGroup) {
+		if cost := as.groupDistance(g) * g.size(); partial+cost < as.best {
+			options = append(options, option{g, cost})
+		}
+	}
+	for i := range as.U {
+		if usedU&(1<<i) != 0 {
+			continue
+		}
+		add(strokeGroup{u: 1 << i, v: 1 << j})
+		for k := i + 1; k < len(as.U); k++ { // two strokes of U for one of V
+			if usedU&(1<<k) == 0 {
+				add(strokeGroup{u: 1<<i | 1<<k, v: 1 << j})
+			}
+		}
+		for k := j + 1; k < len(as.V); k++ { // one stroke of U for two of V
+			if usedV&(1<<k) == 0 {
+				add(strokeGroup{u: 1 << i, v: 1<<j | 1<<k})
+			}
+		}
+	}
+
+	// explore the most promising groups first, to prune early
+	sort.SliceStable(options, func(a, b int) bool { return options[a].cost < options[b].cost })
+	for _, opt := range options {
+		if partial+opt.cost >= as.best { // the best has been updated
+			break
+		}
+		as.path = append(as.path, opt.group)
+		as.search(usedU|opt.group.u, usedV|opt.group.v, partial+opt.cost)
+		as.path = as.path[:len(as.path)-1]
+	}
+}
+
+// groupDistance returns the (cached) distance between the strokes of [g],
+// accepting any direction
+func (as *assignment) groupDistance(g strokeGroup) Fl {
+	if d, has := as.groups[g]; has {
+		return d
+	}
+	u, mergedU := joinStrokes(as.U, g.u)
+	v, mergedV := joinStrokes(as.V, g.v)
+	d := Min(as.w.distanceFootprintNoScale(u, v), as.w.distanceFootprintNoScale(u.reverse(), v))
+	if mergedU || mergedV {
+		d *= mergePenalty
+	}
+	as.groups[g] = d
+	return d
+}
+
+// joinStrokes returns the stroke selected by [mask], or
+// the concatenation of the two strokes selected, and true.
+func joinStrokes(strokes []Stroke, mask uint64) (Stroke, bool) {
+	i := bits.TrailingZeros64(mask)
+	mask &^= 1 << i
+	if mask == 0 {
+		return strokes[i], false
+	}
+	return concatStrokes(strokes[i], strokes[bits.TrailingZeros64(mask)]), true
+}
+
+// concatStrokes joins [a] and [b], choosing their order and direction so
+// that the pen lift between them is as short as possible.
+func concatStrokes(a, b Stroke) Stroke {
+	start := func(s Stroke) Pos { return s.Curves[0].P0 }
+	end := func(s Stroke) Pos { return s.Curves[len(s.Curves)-1].P3 }
+
+	first, second := a, b
+	bestGap := Inf
+	for _, candidate := range [...][2]Stroke{
+		{a, b}, {a, b.reverse()}, {a.reverse(), b}, {a.reverse(), b.reverse()},
+	} {
+		if gap := distP(end(candidate[0]), start(candidate[1])); gap < bestGap {
+			first, second, bestGap = candidate[0], candidate[1], gap
+		}
+	}
+
+	out := Stroke{Curves: make([]Bezier, 0, len(first.Curves)+len(second.Curves))}
+	out.Curves = append(out.Curves, first.Curves...)
+	out.Curves = append(out.Curves, second.Curves...)
+	out.inferArcLengths()
+	return out
+}
@@ -0,0 +1,77 @@
+package symbols
+
+import (
+	"testing"
+
+	tu "github.com/benoitkugler/pen2latex/testutils"
+)
+
+func generateSegment(from, to Pos) Shape {
+	out := make(Shape, 11)
+	for i := range out {
+		out[i] = from.Add(to.Sub(from).ScaleTo(Fl(i) / 10))
+	}
+	return out
+}
+
+func TestUnorderedDistance(t *testing.T) {
+	var (
+		vertical = generateSegment(Pos{0, 0}, Pos{0, 40})
+		top      = generateSegment(Pos{0, 0}, Pos{25, 0})
+		middle   = generateSegment(Pos{0, 20}, Pos{20, 20})
+		bottom   = generateSegment(Pos{0, 40}, Pos{25, 40})
+	)
+	E := Symbol{vertical, top, middle, bottom}.Footprint()
+	// other stroke order, with reversed strokes
+	shuffled := Symbol{middle, bottom.reverse(), top, vertical.reverse()}.Footprint()
+	// the top bar and the vertical drawn at once
+	merged := Symbol{append(top.reverse(), vertical[1:]...), middle, bottom}.Footprint()
+	// an F
+	F := Symbol{vertical, top, middle}.Footprint()
+
+	ordered, unordered := BezierDistance{}, BezierDistance{Unordered: true}
+	tu.Assert(t, ordered.Symbol(merged, E) == Inf)
+	tu.Assert(t, unordered.Symbol(E, E) < 1e-3)
+	tu.Assert(t, unordered.Symbol(shuffled, E) < 1e-3)
+	tu.Assert(t, unordered.Symbol(E, shuffled) < 1e-3)
+	tu.Assert(t, unordered.Symbol(shuffled, E) < ordered.Symbol(shuffled, E))
+
+	dMerged := unordered.Symbol(merged, E)
+	tu.Assert(t, dMerged < Inf)
+	tu.Assert(t, dMerged < unordered.Symbol(merged, F))
+	tu.Assert(t, abs(unordered.Symbol(E, merged)-dMerged) < 1e-3)
+
+	// empty strokes are ignored
+	withEmpty := Footprint{Strokes: append([]Stroke{{}}, shuffled.Strokes...)}
+	tu.Assert(t, unordered.Symbol(withEmpty, E) < 1e-3)
+	tu.Assert(t, unordered.Symbol(E, withEmpty) < 1e-3)
+	tu.Assert(t, unordered.Symbol(Footprint{Strokes: []Stroke{{}}}, E) == Inf)
+	for _, match := range DefaultBezierWeights.matchesUnordered(withEmpty, E, 0) {
+		tu.Assert(t, match.Entry[0] != 0)
+	}
+
+	// the search is bounded
+	limited := BezierDistance{Unordered: true, Budget: 2}
+	tu.Assert(t, limited.Symbol(shuffled, E) >= unordered.Symbol(shuffled, E))
+
+	// on regular inputs, the unordered matching is at least as good
+	for _, group := range symbols {
+		for _, sy := range group.symbols {
+			fp := sy.Footprint()
+			for _, other := range group.symbols {
+				fpOther := other.Footprint()
+				tu.Assert(t, unordered.Symbol(fp, fpOther) <= ordered.Symbol(fp, fpOther)+1e-3)
+			}
+		}
+	}
+}
+
+func TestStoreUnordered(t *testing.T) {
+	db := testStoreAllSamples()
+	db.Distance = BezierDistance{Unordered: true}
+	tu.AssertEqual(t, db.Accuracy(), Fl(1))
+	for _, entry := range db.Symbols {
+		r, _, _ := db.Lookup(entry.Footprint, HeightGrid{})
+		tu.AssertEqual(t, r, entry.R)
+	}
+}
@@ -124,33 +124,10 @@ func (b Bezier) arcLength() Fl {
 	return length
 }
 
-// after clippping return the approximated line portions that intersects
-// taken from https://stackoverflow.com/a/4041286
+// HasIntersection returns true if the curves cross,
+// see [Bezier.Intersections] to get the intersection points.
 func (U Bezier) HasIntersection(other Bezier) bool {
-	const threshold = 0.1
-	var aux func(b1, b2 Bezier) bool
-	aux = func(b1, b2 Bezier) bool {
-		bbox1, bbox2 := b1.controlBox(), b2.controlBox()
-
-		if bbox1.Intersection(bbox2).IsEmpty() {
-			return false
-		}
-
-		a1, a2 := b1.arcLength(), b2.arcLength()
-		if a1+a2 < threshold { // found one intersection
-			return true
-		}
-
-		// recurse
-		b11, b12 := b1.splitAt(0.5)
-		b21, b22 := b2.splitAt(0.5)
-		return aux(b11, b21) ||
-			aux(b11, b22) ||
-			aux(b12, b21) ||
-			aux(b12, b22)
-	}
-
-	return aux(U, other)
+	return len(U.Intersections(other)) != 0
 }
 
 // returns true if the curve describes one point
@@ -276,6 +253,9 @@ func (b *Bezier) normalize() (center Pos, c, s Fl) {
 // return roots in [0,1]
 func quadraticRoots(a, b, c Fl) []Fl {
 	if a == 0 {
+		if b == 0 { // constant derivative, for instance for horizontal or vertical lines
+			return nil
+		}
 		t := -c / b
 		return []Fl{t}
 	}
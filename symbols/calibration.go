@@ -0,0 +1,165 @@
+package symbols
+
+import "sort"
+
+// Calibration is used to convert the distances returned by
+// [Store.Lookup] (which have no absolute meaning) into
+// confidence values in [0,1].
+//
+// It is estimated from the distances between the samples of the store,
+// see [Store.Calibrate].
+type Calibration struct {
+	// Scale is the distance for which the confidence is 0.5.
+	// A zero value means the store is not calibrated.
+	Scale Fl
+}
+
+// IsZero returns true if the calibration has not been computed.
+func (ca Calibration) IsZero() bool { return ca.Scale <= 0 }
+
+// Confidence maps the distance [d] to [0,1], 1 meaning a perfect match.
+// It returns 1 for an uncalibrated store, unless [d] is infinite.
+func (ca Calibration) Confidence(d Fl) Fl {
+	if d == Inf {
+		return 0
+	}
+	if ca.IsZero() {
+		return 1
+	}
+	r := d / ca.Scale
+	return 1 / (1 + r*r)
+}
+
+// Calibrate estimates the [Calibration] of the store, using the distances
+// between its samples :
+//   - the nearest distance between samples of the same rune gives
+//     the typical distance of a correct match
+//   - the nearest distance between samples of different runes gives
+//     the typical distance of a wrong match
+//
+// The scale is chosen between these two values.
+// If the store has only one sample per rune, the half of the typical distance between
+// different runes is used.
+//
+// The complexity is quadratic in the number of entries, so this
+// method should be called once the store is setup, not before each lookup.
+func (db *Store) Calibrate() {
+	var intra, inter []Fl
+	idx := db.getIndex()
+	active := idx.active // ignore the entries hidden by another layer
+	metric := db.metric()
+	for i, entry := range db.Symbols {
+		if !active[i] {
+			continue
+		}
+		bestIntra, bestInter := Inf, Inf
+		for j, other := range db.Symbols {
+			if i == j || !active[j] {
+				continue
+			}
+			// the rotations are not used here, to keep the cost reasonable
+			d := metric.Symbol(db.entry(idx, i), db.entry(idx, j))
+			if entry.R == other.R {
+				bestIntra = Min(bestIntra, d)
+			} else {
+				bestInter = Min(bestInter, d)
+			}
+		}
+		if bestIntra < Inf {
+			intra = append(intra, bestIntra)
+		}
+		if bestInter < Inf {
+			inter = append(inter, bestInter)
+		}
+	}
+
+	if len(inter) == 0 { // not enough data
+		db.Calibration = Calibration{}
+		return
+	}
+
+	medianInter := median(inter)
+	scale := medianInter / 2
+	if len(intra) != 0 {
+		scale = (median(intra) + medianInter) / 2
+	}
+	db.Calibration = Calibration{Scale: scale}
+}
+
+// Accuracy returns the proportion of the samples which are correctly
+// recognized by the other samples of the store : for each sample, the closest
+// other sample (using the metric of the store) should have the same rune.
+// It is useful to compare the metrics (see [Store.Distance]) on a given store.
+// Runes with only one sample are ignored, and -1 is returned if there is no other sample.
+//
+// As for [Store.Calibrate], the complexity is quadratic in the number of entries.
+func (db *Store) Accuracy() Fl {
+	idx := db.getIndex()
+	var (
+		labels []rune
+		fps    []Footprint
+	)
+	for i, entry := range db.Symbols {
+		if idx.active[i] {
+			labels = append(labels, entry.R)
+			fps = append(fps, db.entry(idx, i))
+		}
+	}
+	return leaveOneOutAccuracy(db.metric(), labels, fps)
+}
+
+// leaveOneOutAccuracy returns the proportion of [fps] whose closest
+// other footprint has the same label, ignoring the labels with only one sample,
+// or -1 if there is no sample to test.
+func leaveOneOutAccuracy(metric Distance, labels []rune, fps []Footprint) Fl {
+	samplesCount := map[rune]int{}
+	for _, r := range labels {
+		samplesCount[r]++
+	}
+	var correct, total int
+	for i, r := range labels {
+		if samplesCount[r] < 2 {
+			continue
+		}
+		best, bestRune := Inf, rune(0)
+		for j, other := range labels {
+			if i == j {
+				continue
+			}
+			if d := metric.Symbol(fps[j], fps[i]); d < best {
+				best, bestRune = d, other
+			}
+		}
+		total++
+		if bestRune == r {
+			correct++
+		}
+	}
+	if total == 0 {
+		return -1
+	}
+	return Fl(correct) / Fl(total)
+}
+
+// Confidence returns the confidence for a match with distance [d],
+// using the calibration of the store.
+func (db *Store) Confidence(d Fl) Fl { return db.Calibration.Confidence(d) }
+
+// isRejected returns true if a match with distance [d] should
+// not be accepted.
+func (db *Store) isRejected(d Fl) bool {
+	if db.Rejection <= 0 || db.Calibration.IsZero() {
+		return false
+	}
+	return db.Confidence(d) < db.Rejection
+}
+
+// median sorts [values] in place and returns its median
+func median(values []Fl) Fl {
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	L := len(values)
+	if L%2 == 1 {
+		return values[L/2]
+	}
+	return (values[L/2-1] + values[L/2]) / 2
+}
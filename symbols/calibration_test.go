@@ -0,0 +1,75 @@
+package symbols
+
+import (
+	"testing"
+
+	tu "github.com/benoitkugler/pen2latex/testutils"
+)
+
+func TestCalibrationConfidence(t *testing.T) {
+	var ca Calibration
+	tu.Assert(t, ca.IsZero())
+	tu.AssertEqual(t, ca.Confidence(12), Fl(1))
+	tu.AssertEqual(t, ca.Confidence(Inf), Fl(0))
+
+	ca = Calibration{Scale: 2}
+	tu.AssertEqual(t, ca.Confidence(0), Fl(1))
+	tu.AssertEqual(t, ca.Confidence(2), Fl(0.5))
+	tu.Assert(t, ca.Confidence(1) > ca.Confidence(3))
+	tu.Assert(t, ca.Confidence(1e6) >= 0)
+}
+
+func testStoreAllSamples() Store {
+	samples := map[rune][]Symbol{}
+	for _, group := range symbols {
+		r := rune(group.description[0])
+		samples[r] = group.symbols
+	}
+	return NewStoreFromSamples(samples)
+}
+
+func TestCalibrate(t *testing.T) {
+	var empty Store
+	empty.Calibrate()
+	tu.Assert(t, empty.Calibration.IsZero())
+
+	db := testStoreAllSamples()
+	db.Calibrate()
+	tu.Assert(t, !db.Calibration.IsZero())
+
+	// samples of the store are recognized with a good confidence
+	for _, group := range symbols {
+		for _, symbol := range group.symbols {
+			candidates := db.LookupN(symbol.Footprint(), HeightGrid{}, 1)
+			tu.Assert(t, candidates[0].Confidence > 0.5)
+		}
+	}
+}
+
+func TestRejection(t *testing.T) {
+	db := testStoreAllSamples()
+	db.Calibrate()
+
+	// a scribble, which is not a symbol of the store
+	input := Symbol{
+		generateCircle(Pos{30, 30}, 20, 30),
+		generateCircle(Pos{90, 30}, 5, 30),
+	}.Footprint()
+
+	r, d, _ := db.Lookup(input, HeightGrid{})
+	tu.Assert(t, r != 0) // no rejection by default
+	conf := db.Confidence(d)
+	tu.Assert(t, conf < 0.5)
+
+	db.Rejection = conf + 0.01
+	r, d2, _ := db.Lookup(input, HeightGrid{})
+	tu.AssertEqual(t, r, rune(0))
+	tu.AssertEqual(t, d2, d)
+
+	// regular inputs are still accepted
+	db.Rejection = 0.5
+	for _, group := range symbols {
+		r, _, _ := db.Lookup(group.symbols[0].Footprint(), HeightGrid{})
+		tu.AssertEqual(t, r, rune(group.description[0]))
+	}
+}
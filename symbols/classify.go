@@ -0,0 +1,127 @@
+package symbols
+
+// This file gathers the stroke analysis helpers into a public
+// description, see [ClassifyStroke].
+
+// Corner is a sharp junction between two consecutive curves of a stroke.
+type Corner struct {
+	Curve int // the index of the curve ending at the corner
+	Pos   Pos // the position of the corner
+	Angle Fl  // the angle between the tangents, in degrees
+}
+
+// StrokeDescription summarizes the shape of a stroke,
+// as returned by [ClassifyStroke].
+type StrokeDescription struct {
+	// Kind is the kind of the primitive covering the whole stroke, or [FreeCurve]
+	// if the stroke is made of several primitives (see [Stroke.Primitives]).
+	Kind       PrimitiveKind
+	Primitives []Primitive
+
+	// IsPoint is true if the stroke is reduced to a single point.
+	IsPoint bool
+
+	// Closure is the distance between the first and the last point,
+	// relative to the size of the stroke.
+	Closure Fl
+	// Closed is true if the stroke ends (roughly) where it starts,
+	// with the same tolerance as [Stroke.SelfIntersections].
+	Closed bool
+
+	// Direction is the angle of the vector from the first to the last point,
+	// in degrees, in [-180, 180] : 0 for a stroke drawn from left to right,
+	// 90 for a stroke drawn downward (the Y axis being downward).
+	Direction Fl
+	// Rotation is the total signed angle the pen turns along the stroke, in degrees :
+	// it is positive for a clockwise rotation on screen, and about 360 for a circle.
+	Rotation Fl
+
+	// Corners are the sharp junctions between curves, in drawing order.
+	Corners []Corner
+
+	// Loops is the number of loops, counting both the closed
+	// elliptic primitives and the self intersections.
+	Loops int
+
+	// StartsWithLine is true if the stroke starts with a line, followed
+	// by a corner, like in 5 or 7.
+	StartsWithLine bool
+}
+
+// ClassifyStroke analyses the shape of [fp]. The thresholds used
+// are the ones of the fitting (see [DefaultFitOptions]) and of [Stroke.Primitives].
+func ClassifyStroke(fp Stroke) StrokeDescription {
+	var out StrokeDescription
+	if len(fp.Curves) == 0 {
+		return out
+	}
+	if _, ok := fp.IsPoint(); ok {
+		out.IsPoint, out.Closed = true, true
+		return out
+	}
+
+	out.Primitives = fp.Primitives()
+	out.Kind = FreeCurve
+	if len(out.Primitives) == 1 {
+		out.Kind = out.Primitives[0].Kind
+	}
+
+	start, end := fp.Curves[0].P0, fp.Curves[len(fp.Curves)-1].P3
+	bbox := fp.boundingBox()
+	if size := Max(bbox.Width(), bbox.Height()); size > 0 {
+		out.Closure = distP(start, end) / size
+	}
+	out.Closed = out.Closure < closingTolerance
+	if start != end {
+		out.Direction = angle(Pos{1, 0}, end.Sub(start))
+	}
+	out.Rotation = fp.rotation()
+
+	for i := 1; i < len(fp.Curves); i++ {
+		c1, c2 := fp.Curves[i-1], fp.Curves[i]
+		if a := tangentAngle(c1, c2); a >= DefaultFitOptions.CornerAngle {
+			out.Corners = append(out.Corners, Corner{Curve: i - 1, Pos: c1.P3, Angle: a})
+		}
+	}
+
+	var loops int
+	for _, pr := range out.Primitives {
+		if pr.Kind == Loop {
+			loops++
+		}
+	}
+	// a loop drawn with an overlap also crosses itself : do not count it twice
+	if crossings := len(fp.SelfIntersections()); crossings > loops {
+		loops = crossings
+	}
+	out.Loops = loops
+
+	out.StartsWithLine = fp.hasStartLine()
+	return out
+}
+
+// rotation returns the sum of the signed angles between
+// consecutive directions of the stroke
+func (fp Stroke) rotation() Fl {
+	var points Shape
+	for _, cu := range fp.Curves {
+		points = append(points, cu.toPoints()...)
+	}
+	points = append(points, fp.Curves[len(fp.Curves)-1].P3)
+
+	var (
+		total Fl
+		last  Pos
+	)
+	for i := 1; i < len(points); i++ {
+		dir := points[i].Sub(points[i-1])
+		if dir.NormSquared() == 0 {
+			continue
+		}
+		if last != (Pos{}) {
+			total += angle(last, dir)
+		}
+		last = dir
+	}
+	return total
+}
@@ -0,0 +1,53 @@
+package symbols
+
+import (
+	"testing"
+
+	tu "github.com/benoitkugler/pen2latex/testutils"
+)
+
+func TestClassifyStroke(t *testing.T) {
+	tu.AssertEqual(t, ClassifyStroke(Stroke{}).Kind, FreeCurve)
+
+	point := ClassifyStroke(Stroke{Curves: []Bezier{{P0: Pos{2, 2}, P1: Pos{2, 2}, P2: Pos{2, 2}, P3: Pos{2, 2}}}})
+	tu.Assert(t, point.IsPoint && point.Closed)
+
+	line := ClassifyStroke(Stroke{Curves: polyline(Pos{0, 0}, Pos{0, 50})})
+	tu.AssertEqual(t, line.Kind, Segment)
+	tu.Assert(t, !line.Closed && len(line.Corners) == 0 && line.Loops == 0)
+	tu.Assert(t, abs(line.Direction-90) < 1) // downward
+	tu.Assert(t, abs(line.Rotation) < 1)
+
+	circle := ClassifyStroke(newFp(generateEllipse(Pos{30, 30}, 20, 20, 60, 1)))
+	tu.AssertEqual(t, circle.Kind, Loop)
+	tu.Assert(t, circle.Closed)
+	tu.AssertEqual(t, circle.Loops, 1)
+	tu.Assert(t, abs(circle.Rotation-360) < 20) // clockwise, the Y axis being downward
+
+	reversed := ClassifyStroke(newFp(generateEllipse(Pos{30, 30}, 20, 20, 60, 1)).reverse())
+	tu.Assert(t, abs(reversed.Rotation+360) < 20)
+
+	arc := ClassifyStroke(newFp(generateEllipse(Pos{30, 30}, 20, 20, 60, 0.5)))
+	tu.AssertEqual(t, arc.Kind, Arc)
+	tu.Assert(t, !arc.Closed)
+	tu.Assert(t, abs(arc.Direction-180) < 1 || abs(arc.Direction+180) < 1) // from right to left
+
+	// a 7 : one corner, starting with a line
+	seven := ClassifyStroke(Stroke{Curves: polyline(Pos{0, 0}, Pos{30, 0}, Pos{10, 60})})
+	tu.AssertEqual(t, seven.Kind, FreeCurve)
+	tu.AssertEqual(t, len(seven.Corners), 1)
+	tu.AssertEqual(t, seven.Corners[0].Curve, 0)
+	tu.AssertEqual(t, seven.Corners[0].Pos, Pos{30, 0})
+	tu.Assert(t, seven.Corners[0].Angle > 90)
+	tu.Assert(t, seven.StartsWithLine)
+
+	// a triangle, drawn in one stroke
+	triangle := ClassifyStroke(Stroke{Curves: polyline(Pos{0, 0}, Pos{40, 0}, Pos{20, 30}, Pos{0, 0})})
+	tu.Assert(t, triangle.Closed)
+	tu.AssertEqual(t, len(triangle.Corners), 2)
+	tu.Assert(t, abs(triangle.Rotation) > 200)
+
+	// an alpha : the stroke crosses itself
+	alpha := ClassifyStroke(Stroke{Curves: polyline(Pos{40, 0}, Pos{0, 30}, Pos{0, 0}, Pos{40, 30})})
+	tu.AssertEqual(t, alpha.Loops, 1)
+}
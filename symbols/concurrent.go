@@ -0,0 +1,111 @@
+package symbols
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// LookupContext is the same as [Store.Lookup], but computes the distances
+// to the entries of the store concurrently, and stops early
+// if [ctx] is cancelled, returning [ctx.Err()].
+//
+// The result does not depend on the scheduling, and is always
+// the one returned by [Store.Lookup].
+func (db *Store) LookupContext(ctx context.Context, input Footprint, grid HeightGrid) (rune, Fl, bool, error) {
+	distances, distancesCompatible, err := db.distancesContext(ctx, input)
+	if err != nil {
+		return 0, Inf, false, err
+	}
+	r, d, isCompatible := db.lookup(input, grid, distances, distancesCompatible)
+	return r, d, isCompatible, nil
+}
+
+// LookupTraceContext is the same as [Store.LookupTrace], but computes the distances
+// concurrently, and stops early if [ctx] is cancelled, as [Store.LookupContext] does.
+func (db *Store) LookupTraceContext(ctx context.Context, input Footprint, grid HeightGrid) (rune, Fl, bool, Trace, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, Inf, false, Trace{}, err
+	}
+	idx := db.getIndex()
+	prepared := db.prepareInput(idx, input)
+	exactCandidates, compatibleCandidates := db.candidates(idx, prepared)
+	distances, distancesCompatible, err := db.distancesForContext(ctx, idx, prepared, exactCandidates, compatibleCandidates)
+	if err != nil {
+		return 0, Inf, false, Trace{}, err
+	}
+	return db.lookupTrace(ctx, idx, prepared, input, grid, exactCandidates, compatibleCandidates, distances, distancesCompatible)
+}
+
+// distanceJob is one distance computation
+type distanceJob struct {
+	index      int // in [Store.Symbols]
+	compatible bool
+}
+
+// distancesContext is the same as [Store.distances], but
+// distribute the work across goroutines.
+// Each result is written at its own index, so that the output
+// is deterministic.
+func (db *Store) distancesContext(ctx context.Context, input Footprint) (exact, compatible []Fl, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	idx := db.getIndex()
+	prepared := db.prepareInput(idx, input)
+	exactCandidates, compatibleCandidates := db.candidates(idx, prepared)
+	return db.distancesForContext(ctx, idx, prepared, exactCandidates, compatibleCandidates)
+}
+
+// distancesForContext is the same as [Store.distancesFor], but
+// distribute the work across goroutines.
+func (db *Store) distancesForContext(ctx context.Context, idx *storeIndex, prepared matchInput,
+	exactCandidates, compatibleCandidates []int,
+) (exact, compatible []Fl, err error) {
+	jobs := make([]distanceJob, 0, len(exactCandidates)+len(compatibleCandidates))
+	for _, i := range exactCandidates {
+		jobs = append(jobs, distanceJob{index: i})
+	}
+	for _, i := range compatibleCandidates {
+		jobs = append(jobs, distanceJob{index: i, compatible: true})
+	}
+
+	exact, compatible = db.newDistances()
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	var (
+		next int64 // index of the next job to process
+		wg   sync.WaitGroup
+	)
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				j := int(atomic.AddInt64(&next, 1) - 1)
+				if j >= len(jobs) {
+					return
+				}
+				job := jobs[j]
+				d := db.entryDistance(idx, job.index, prepared, job.compatible)
+				if job.compatible {
+					compatible[job.index] = d
+				} else {
+					exact[job.index] = d
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	return exact, compatible, nil
+}
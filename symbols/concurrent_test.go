@@ -0,0 +1,62 @@
+package symbols
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	tu "github.com/benoitkugler/pen2latex/testutils"
+)
+
+func TestLookupContext(t *testing.T) {
+	db := testStoreAllSamples()
+	db.Calibrate()
+	grid := HeightGrid{Ymin: 0, Ymax: 40}
+
+	for _, group := range symbols {
+		for _, sy := range group.symbols {
+			input := sy.Footprint()
+			expR, expD, expCompatible := db.Lookup(input, grid)
+			for range [3]int{} { // the scheduling must not change the result
+				r, d, isCompatible, err := db.LookupContext(context.Background(), input, grid)
+				tu.AssertNoErr(t, err)
+				tu.AssertEqual(t, r, expR)
+				tu.AssertEqual(t, d, expD)
+				tu.AssertEqual(t, isCompatible, expCompatible)
+			}
+		}
+	}
+
+	var empty Store
+	r, _, _, err := empty.LookupContext(context.Background(), symbols[0].symbols[0].Footprint(), grid)
+	tu.AssertNoErr(t, err)
+	tu.AssertEqual(t, r, rune(0))
+}
+
+func TestLookupTraceContext(t *testing.T) {
+	db := testStoreAllSamples()
+	db.Calibrate()
+	grid := HeightGrid{Ymin: 0, Ymax: 40}
+
+	for _, group := range symbols {
+		input := group.symbols[0].Footprint()
+		expR, expD, expCompatible, expTrace := db.LookupTrace(input, grid)
+		r, d, isCompatible, trace, err := db.LookupTraceContext(context.Background(), input, grid)
+		tu.AssertNoErr(t, err)
+		tu.AssertEqual(t, r, expR)
+		tu.AssertEqual(t, d, expD)
+		tu.AssertEqual(t, isCompatible, expCompatible)
+		tu.AssertEqual(t, trace, expTrace)
+	}
+}
+
+func TestLookupContextCancel(t *testing.T) {
+	db := testStoreAllSamples()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, _, err := db.LookupContext(ctx, symbols[0].symbols[0].Footprint(), HeightGrid{})
+	tu.Assert(t, errors.Is(err, context.Canceled))
+	_, _, _, _, err = db.LookupTraceContext(ctx, symbols[0].symbols[0].Footprint(), HeightGrid{})
+	tu.Assert(t, errors.Is(err, context.Canceled))
+}
@@ -1,79 +1,391 @@
 package symbols
 
+import (
+	"strings"
+	"unicode"
+)
+
 // distinguish using size
 
 // HeightGrid defines the reference for the Y coordinate.
 type HeightGrid struct {
 	Ymin, Ymax Fl
 	Baseline   Fl // Y coordinate, with Ymin <= Baseline <= Ymax
+
+	// The following typographic lines are optional (zero meaning unknown),
+	// and are usually estimated from the symbols already written, see [HeightGrid.Estimate].
+	// They are expressed as (positive) distances to the baseline.
+
+	XHeight   Fl // the height of the lowercase letters, like x
+	CapHeight Fl // the height of the capital letters, the digits and the ascenders, like b
+	Descender Fl // the depth of the descenders, like g, below the baseline
 }
 
 func (ct HeightGrid) height() Fl { return ct.Ymax - ct.Ymin }
 
-func distinguishByContext(fp Footprint, context HeightGrid, r rune) rune {
-	switch r {
-	case 'j', 'J':
-		if isOverBaseline(fp, context) {
-			return 'J'
-		}
-		return 'j'
-	case 'o', 'O':
-		if isUpperSize(fp, context) {
-			return 'O'
+// fields returns pointers to the values of the grid, in a fixed order
+func (ct *HeightGrid) fields() []*Fl {
+	return []*Fl{&ct.Ymin, &ct.Ymax, &ct.Baseline, &ct.XHeight, &ct.CapHeight, &ct.Descender}
+}
+
+// upperHeight returns the height between the top and the baseline
+func (ct HeightGrid) upperHeight() Fl { return ct.Baseline - ct.Ymin }
+
+// hasLines returns true if at least one typographic line is known
+func (ct HeightGrid) hasLines() bool {
+	return ct.XHeight > 0 || ct.CapHeight > 0 || ct.Descender > 0
+}
+
+// lines returns the typographic lines of the grid, using
+// the default profiles for the unknown ones
+func (ct HeightGrid) lines() (xHeight, capHeight, descender Fl) {
+	h := ct.upperHeight()
+	xHeight, capHeight, descender = ct.XHeight, ct.CapHeight, ct.Descender
+	if xHeight <= 0 {
+		xHeight = lowerProfile.Height * h
+	}
+	if capHeight <= 0 {
+		capHeight = upperProfile.Height * h
+	}
+	if descender <= 0 {
+		descender = descenderProfile.Bottom * h
+	}
+	return xHeight, capHeight, descender
+}
+
+// Estimate returns a copy of the grid, whose typographic lines are the average
+// of the lines reached by the [written] symbols, according to the [VerticalClass] of their rune.
+// The lines not reached by any symbol are not modified.
+func (ct HeightGrid) Estimate(written []RuneFootprint) HeightGrid {
+	var (
+		sums   [3]Fl // x-height, cap height, descender
+		counts [3]int
+	)
+	add := func(line int, v Fl) {
+		sums[line] += v
+		counts[line]++
+	}
+	for _, symbol := range written {
+		if len(symbol.Footprint.Strokes) == 0 {
+			continue
 		}
-		return 'o'
-	case 'p', 'P':
-		if isUpperSize(fp, context) {
-			return 'P'
+		bbox := symbol.Footprint.BoundingBox()
+		top, bottom := ct.Baseline-bbox.UL.Y, bbox.LR.Y-ct.Baseline
+		switch VerticalClassOf(symbol.R) {
+		case XHeightClass:
+			add(0, top)
+		case AscenderClass:
+			add(1, top)
+		case DescenderClass:
+			add(0, top)
+			add(2, bottom)
 		}
-		return 'p'
-	case 's', 'S':
-		if isUpperSize(fp, context) {
-			return 'S'
+	}
+
+	out := ct
+	for line, field := range [...]*Fl{&out.XHeight, &out.CapHeight, &out.Descender} {
+		if counts[line] != 0 && sums[line] > 0 {
+			*field = sums[line] / Fl(counts[line])
 		}
-		return 's'
-	case 'u', 'U':
-		if isUpperSize(fp, context) {
-			return 'U'
+	}
+	return out
+}
+
+// VerticalClass is the typographic class of a rune,
+// given by the lines of the [HeightGrid] it reaches.
+type VerticalClass uint8
+
+const (
+	NoClass        VerticalClass = iota // the symbols without typical position, like operators
+	XHeightClass                        // between the baseline and the x-height, like x
+	AscenderClass                       // between the baseline and the cap height, like b, A or 9
+	DescenderClass                      // between the descender and the x-height, like g
+)
+
+// VerticalClassOf returns the typographic class of [r].
+func VerticalClassOf(r rune) VerticalClass {
+	switch {
+	case strings.ContainsRune("acemnorsuvwxzαεικνοπστυω", r):
+		return XHeightClass
+	case strings.ContainsRune("gjpqyγημρχ", r):
+		return DescenderClass
+	case strings.ContainsRune("bdhklδθλ", r), unicode.IsDigit(r), unicode.IsUpper(r):
+		return AscenderClass
+	default:
+		return NoClass
+	}
+}
+
+const (
+	// classTolerance is the mismatch (relative to the cap height)
+	// accepted without penalty by [HeightGrid.classMismatch]
+	classTolerance = 0.15
+	// classPenalty is the weight of the class mismatch
+	// when scoring the entries
+	classPenalty = 2
+)
+
+// classMismatch returns the distance between the vertical extent of [bbox]
+// and the one expected for [class], relative to the cap height, or 0
+// if it is below [classTolerance].
+func (ct HeightGrid) classMismatch(bbox Rect, class VerticalClass) Fl {
+	xHeight, capHeight, descender := ct.lines()
+	var expectedTop, expectedBottom Fl
+	switch class {
+	case XHeightClass:
+		expectedTop = xHeight
+	case AscenderClass:
+		expectedTop = capHeight
+	case DescenderClass:
+		expectedTop, expectedBottom = xHeight, descender
+	default:
+		return 0
+	}
+	top, bottom := ct.Baseline-bbox.UL.Y, bbox.LR.Y-ct.Baseline
+	mismatch := (abs(top-expectedTop) + abs(bottom-expectedBottom)) / capHeight
+	return Max(mismatch-classTolerance, 0)
+}
+
+// classPenalties returns, for each entry of the store, the factor (at least 1) applied to
+// its distance when ranking the entries : it is greater than 1 when the rune
+// has a [VerticalClass] inconsistent with the position of [input] in [context].
+// The class used is the one of the size variant the entry resolves to (see [Store.distinguishByContext]),
+// so that a large o is not penalized, since it is returned as O.
+// It returns nil if the typographic lines of [context] are unknown.
+func (db *Store) classPenalties(input Footprint, context HeightGrid) []Fl {
+	if !context.hasLines() || context.upperHeight() <= 0 || len(input.Strokes) == 0 {
+		return nil
+	}
+	bbox := input.BoundingBox()
+	var factors [DescenderClass + 1]Fl
+	for class := range factors {
+		factors[class] = 1 + classPenalty*context.classMismatch(bbox, VerticalClass(class))
+	}
+	classes := map[rune]VerticalClass{} // cache the variant resolution
+	out := make([]Fl, len(db.Symbols))
+	for i, entry := range db.Symbols {
+		class, has := classes[entry.R]
+		if !has {
+			class = VerticalClassOf(db.distinguishByContext(input, context, entry.R))
+			classes[entry.R] = class
 		}
-		return 'u'
-	case 'v', 'V':
-		if isUpperSize(fp, context) {
-			return 'V'
+		out[i] = factors[class]
+	}
+	return out
+}
+
+// penalized returns the [distances] multiplied by [penalties],
+// which may be nil.
+func penalized(distances, penalties []Fl) []Fl {
+	if penalties == nil {
+		return distances
+	}
+	out := make([]Fl, len(distances))
+	for i, d := range distances {
+		out[i] = d * penalties[i]
+	}
+	return out
+}
+
+// penaltyAt returns penalties[i], or 1 for nil [penalties]
+func penaltyAt(penalties []Fl, i int) Fl {
+	if penalties == nil {
+		return 1
+	}
+	return penalties[i]
+}
+
+// SizeProfile is the typical size and vertical position of a rune,
+// relative to the [HeightGrid] it is drawn in.
+// Both values are expressed in units of the height between the top
+// of the grid and its baseline.
+type SizeProfile struct {
+	Height Fl // the height of the bounding box
+	Bottom Fl // the position of the bottom of the bounding box, below the baseline
+}
+
+// newSizeProfile measures [fp] in [grid], which must have a positive upper height.
+func newSizeProfile(fp Footprint, grid HeightGrid) SizeProfile {
+	h := grid.upperHeight()
+	bbox := fp.BoundingBox()
+	return SizeProfile{
+		Height: bbox.Height() / h,
+		Bottom: (bbox.LR.Y - grid.Baseline) / h,
+	}
+}
+
+func (sp SizeProfile) distance(other SizeProfile) Fl {
+	return Pos{sp.Height - other.Height, sp.Bottom - other.Bottom}.Norm()
+}
+
+// SizeVariant is one rune of a [SizeVariants] group.
+type SizeVariant struct {
+	R rune
+	SizeProfile
+}
+
+// SizeVariants is a group of runes drawn with the same shape, only
+// distinguished by their size and vertical position, like o and O.
+//
+// When one of these runes is matched, the variant whose profile is the
+// closest to the input is returned (the matched rune being kept
+// in case of equality, and the first variants being preferred otherwise).
+type SizeVariants []SizeVariant
+
+// profiles used by [DefaultSizeVariants]
+var (
+	lowerProfile      = SizeProfile{Height: 0.3}
+	upperProfile      = SizeProfile{Height: 0.7}
+	descenderProfile  = SizeProfile{Height: 0.6, Bottom: 0.3}
+	fullHeightProfile = SizeProfile{Height: 1, Bottom: 0.2}
+)
+
+// DefaultSizeVariants is used when [Store.Variants] is empty.
+// The profiles are rough estimations, which should be learned from
+// the samples of the user, see [Store.LearnSizes].
+var DefaultSizeVariants = []SizeVariants{
+	{{'c', lowerProfile}, {'C', upperProfile}},
+	{{'j', descenderProfile}, {'J', upperProfile}},
+	{{'o', lowerProfile}, {'O', upperProfile}},
+	{{'p', descenderProfile}, {'P', upperProfile}},
+	{{'s', lowerProfile}, {'S', upperProfile}},
+	{{'u', lowerProfile}, {'U', upperProfile}},
+	{{'v', lowerProfile}, {'V', upperProfile}},
+	{{'w', lowerProfile}, {'W', upperProfile}},
+	{{'x', lowerProfile}, {'X', upperProfile}},
+	{{'z', lowerProfile}, {'Z', upperProfile}},
+	{{'π', lowerProfile}, {'Π', upperProfile}},
+	{{'l', upperProfile}, {'|', fullHeightProfile}},
+}
+
+// LearnedSizeVariants are the runes with the same typical size, like k and K,
+// which are only distinguished once their profiles are learned :
+// they are added by [Store.LearnSizes] to the group containing one of them
+// (or to a new group) when at least two runes of a group have samples.
+var LearnedSizeVariants = [][]rune{
+	{'k', 'K'},
+	{'O', '0'},
+	{'l', '1'},
+}
+
+// variants returns the size variants used by the store
+func (db *Store) variants() []SizeVariants {
+	if len(db.Variants) == 0 {
+		return DefaultSizeVariants
+	}
+	return db.Variants
+}
+
+// distinguishByContext returns the variant of [r] best suited to the size
+// of [fp] in [context].
+// If the context has no height, [r] is returned.
+func (db *Store) distinguishByContext(fp Footprint, context HeightGrid, r rune) rune {
+	if context.upperHeight() <= 0 {
+		return r
+	}
+	for _, group := range db.variants() {
+		index := group.index(r)
+		if index == -1 {
+			continue
 		}
-		return 'v'
-	case 'w', 'W':
-		if isUpperSize(fp, context) {
-			return 'W'
+		profile := newSizeProfile(fp, context)
+		best, bestDistance := r, group[index].distance(profile)
+		for _, variant := range group {
+			if d := variant.distance(profile); d < bestDistance {
+				best, bestDistance = variant.R, d
+			}
 		}
-		return 'w'
-	case 'z', 'Z':
-		if isUpperSize(fp, context) {
-			return 'Z'
+		return best
+	}
+	return r
+}
+
+// index returns the index of [r] in the group, or -1
+func (sv SizeVariants) index(r rune) int {
+	for i, variant := range sv {
+		if variant.R == r {
+			return i
 		}
-		return 'z'
-	case 'π', 'Π':
-		if isUpperSize(fp, context) {
-			return 'Π'
+	}
+	return -1
+}
+
+// LearnSizes updates the profiles of the [Store.Variants], using
+// the samples of the user layer, measured in the grid they were drawn in
+// (the samples without grid are ignored).
+// Each rune with samples uses the average profile of its samples, the
+// other ones keep their current profile.
+// The groups of [LearnedSizeVariants] are also added, see its documentation.
+func (db *Store) LearnSizes() {
+	// copy the current groups, so that the defaults are never modified
+	groups := make([]SizeVariants, len(db.variants()))
+	for i, group := range db.variants() {
+		groups[i] = append(SizeVariants(nil), group...)
+	}
+
+	for _, group := range groups {
+		for i, variant := range group {
+			if profile, ok := db.learnedProfile(variant.R); ok {
+				group[i].SizeProfile = profile
+			}
 		}
-		return 'π'
 	}
 
-	return r
+	for _, runes := range LearnedSizeVariants {
+		var learned SizeVariants
+		for _, r := range runes {
+			if profile, ok := db.learnedProfile(r); ok {
+				learned = append(learned, SizeVariant{R: r, SizeProfile: profile})
+			}
+		}
+		if len(learned) < 2 {
+			continue
+		}
+		groups = mergeSizeVariants(groups, learned)
+	}
+	db.Variants = groups
 }
 
-// return true if the symbol is over the baseline
-func isOverBaseline(fp Footprint, context HeightGrid) bool {
-	bottom := fp.BoundingBox().LR.Y
-	contextHeight := context.height()
-
-	return bottom <= context.Baseline+contextHeight*0.1
+// learnedProfile returns the average profile of the samples of [r]
+// in the user layer, or false if no sample has a grid
+func (db *Store) learnedProfile(r rune) (SizeProfile, bool) {
+	start, end := db.layerRange(r, UserLayer)
+	var (
+		sum SizeProfile
+		n   int
+	)
+	for _, entry := range db.Symbols[start:end] {
+		if entry.Grid.upperHeight() <= 0 {
+			continue
+		}
+		profile := newSizeProfile(entry.Footprint, entry.Grid)
+		sum.Height += profile.Height
+		sum.Bottom += profile.Bottom
+		n++
+	}
+	if n == 0 {
+		return SizeProfile{}, false
+	}
+	return SizeProfile{Height: sum.Height / Fl(n), Bottom: sum.Bottom / Fl(n)}, true
 }
 
-// return true if the symbol is more than half the height (between top and baseline)
-func isUpperSize(fp Footprint, context HeightGrid) bool {
-	h := abs(context.Ymin - context.Baseline)
-	bbox := fp.BoundingBox()
-	ratio := bbox.Height() / h
-	return ratio >= 0.5
+// mergeSizeVariants adds the [variants] missing in the group
+// containing one of them, or appends a new group.
+func mergeSizeVariants(groups []SizeVariants, variants SizeVariants) []SizeVariants {
+	for i, group := range groups {
+		hasCommon := false
+		for _, variant := range variants {
+			hasCommon = hasCommon || group.index(variant.R) != -1
+		}
+		if !hasCommon {
+			continue
+		}
+		for _, variant := range variants {
+			if group.index(variant.R) == -1 {
+				groups[i] = append(groups[i], variant)
+			}
+		}
+		return groups
+	}
+	return append(groups, variants)
 }
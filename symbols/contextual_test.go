@@ -0,0 +1,203 @@
+package symbols
+
+import (
+	"strings"
+	"testing"
+
+	tu "github.com/benoitkugler/pen2latex/testutils"
+)
+
+// generateBoxSymbol returns a circle with the given vertical extent
+func generateBoxSymbol(top, bottom Fl) Symbol {
+	r := (bottom - top) / 2
+	return Symbol{generateEllipse(Pos{30, top + r}, r, r, 40, 1)} // closed
+}
+
+// generateBox returns a footprint with the given vertical extent
+func generateBox(top, bottom Fl) Footprint { return generateBoxSymbol(top, bottom).Footprint() }
+
+func TestDistinguishByContext(t *testing.T) {
+	var db Store
+	grid := HeightGrid{Ymin: 0, Ymax: 90, Baseline: 60}
+
+	small, large := generateBox(40, 60), generateBox(10, 60)
+	tu.AssertEqual(t, db.distinguishByContext(small, grid, 'O'), 'o')
+	tu.AssertEqual(t, db.distinguishByContext(large, grid, 'o'), 'O')
+	// 0 and O have the same typical size : 0 is only distinguished once learned
+	tu.AssertEqual(t, db.distinguishByContext(large, grid, '0'), '0')
+	tu.AssertEqual(t, db.distinguishByContext(small, grid, '0'), '0')
+	// the default groups have distinct profiles
+	for _, group := range DefaultSizeVariants {
+		for i := range group {
+			for j := i + 1; j < len(group); j++ {
+				tu.Assert(t, group[i].SizeProfile != group[j].SizeProfile)
+			}
+		}
+	}
+	// runes without variants are not changed
+	tu.AssertEqual(t, db.distinguishByContext(small, grid, 'a'), 'a')
+	// no context
+	tu.AssertEqual(t, db.distinguishByContext(small, HeightGrid{}, 'O'), 'O')
+
+	// descenders
+	tu.AssertEqual(t, db.distinguishByContext(generateBox(30, 75), grid, 'P'), 'p')
+	tu.AssertEqual(t, db.distinguishByContext(generateBox(15, 60), grid, 'p'), 'P')
+	tu.AssertEqual(t, db.distinguishByContext(generateBox(30, 75), grid, 'J'), 'j')
+
+	// custom variants
+	db.Variants = []SizeVariants{{{'a', SizeProfile{Height: 0.3}}, {'A', SizeProfile{Height: 0.7}}}}
+	tu.AssertEqual(t, db.distinguishByContext(large, grid, 'a'), 'A')
+	tu.AssertEqual(t, db.distinguishByContext(large, grid, 'o'), 'o')
+}
+
+func TestLearnSizes(t *testing.T) {
+	grid := HeightGrid{Ymin: 0, Ymax: 90, Baseline: 60}
+
+	// this user draws large lowercase letters
+	var db Store
+	db.Add('x', generateBoxSymbol(30, 60))
+	db.AddWithInfo('x', generateBoxSymbol(28, 60), nil, grid)
+	db.AddWithInfo('x', generateBoxSymbol(30, 60), nil, grid)
+	db.AddWithInfo('X', generateBoxSymbol(5, 60), nil, grid)
+
+	input := generateBox(25, 60) // 0.58 of the upper height
+	tu.AssertEqual(t, db.distinguishByContext(input, grid, 'x'), 'X')
+
+	// the samples without grid are ignored
+	var noGrid Store
+	noGrid.Add('x', generateBoxSymbol(30, 60))
+	noGrid.LearnSizes()
+	tu.AssertEqual(t, noGrid.Variants, DefaultSizeVariants)
+
+	db.LearnSizes()
+	tu.AssertEqual(t, db.distinguishByContext(input, grid, 'x'), 'x')
+	tu.AssertEqual(t, db.distinguishByContext(input, grid, 'X'), 'x')
+	tu.AssertEqual(t, db.distinguishByContext(generateBox(2, 60), grid, 'x'), 'X')
+	// each sample is measured in its own grid
+	db.AddWithInfo('X', generateBoxSymbol(5+90, 60+90), nil, HeightGrid{Ymin: 90, Ymax: 180, Baseline: 150})
+	db.LearnSizes()
+	tu.AssertEqual(t, db.distinguishByContext(generateBox(2, 60), grid, 'x'), 'X')
+	// the defaults are not modified
+	tu.AssertEqual(t, DefaultSizeVariants[8][0].SizeProfile, lowerProfile)
+	// other groups are kept
+	tu.AssertEqual(t, db.distinguishByContext(generateBox(40, 60), grid, 'O'), 'o')
+	tu.AssertEqual(t, db.distinguishByContext(generateBox(40, 60), grid, 'k'), 'k')
+
+	// runes with the same typical size are distinguished once learned
+	db.AddWithInfo('k', generateBoxSymbol(10, 60), nil, grid)
+	db.AddWithInfo('K', generateBoxSymbol(0, 70), nil, grid)
+	db.AddWithInfo('0', generateBoxSymbol(15, 60), nil, grid)
+	db.AddWithInfo('O', generateBoxSymbol(5, 60), nil, grid)
+	db.LearnSizes()
+	tu.AssertEqual(t, db.distinguishByContext(generateBox(10, 60), grid, 'K'), 'k')
+	tu.AssertEqual(t, db.distinguishByContext(generateBox(0, 70), grid, 'k'), 'K')
+	tu.AssertEqual(t, db.distinguishByContext(generateBox(15, 60), grid, 'O'), '0')
+	tu.AssertEqual(t, db.distinguishByContext(generateBox(40, 60), grid, '0'), 'o')
+	// learning again does not duplicate the variants
+	variants := db.Variants
+	db.LearnSizes()
+	tu.AssertEqual(t, db.Variants, variants)
+
+	// the learned profiles are saved
+	db2, err := parseStore(encodeStore(db))
+	tu.AssertNoErr(t, err)
+	tu.AssertEqual(t, db2.Variants, db.Variants)
+}
+
+func TestEstimateGrid(t *testing.T) {
+	grid := HeightGrid{Ymin: 0, Ymax: 90, Baseline: 60}
+	tu.AssertEqual(t, grid.Estimate(nil), grid)
+	tu.Assert(t, !grid.hasLines())
+
+	got := grid.Estimate([]RuneFootprint{
+		{R: 'x', Footprint: generateBox(40, 60)},
+		{R: 'b', Footprint: generateBox(20, 60)},
+		{R: 'g', Footprint: generateBox(42, 78)},
+		{R: '+', Footprint: generateBox(0, 90)}, // ignored
+	})
+	tu.Assert(t, got.hasLines())
+	tu.Assert(t, abs(got.XHeight-19) < 0.5)
+	tu.Assert(t, abs(got.CapHeight-40) < 0.5)
+	tu.Assert(t, abs(got.Descender-18) < 0.5)
+
+	// unknown lines keep their value
+	partial := HeightGrid{Ymin: 0, Ymax: 90, Baseline: 60, CapHeight: 35}.Estimate([]RuneFootprint{
+		{R: 'x', Footprint: generateBox(40, 60)},
+	})
+	tu.AssertEqual(t, partial.CapHeight, Fl(35))
+	tu.AssertEqual(t, partial.Descender, Fl(0))
+}
+
+func TestVerticalClassOf(t *testing.T) {
+	for r, class := range map[rune]VerticalClass{
+		'x': XHeightClass, 'o': XHeightClass,
+		'b': AscenderClass, 'P': AscenderClass, '9': AscenderClass,
+		'g': DescenderClass, 'q': DescenderClass, 'p': DescenderClass,
+		'+': NoClass, '=': NoClass,
+	} {
+		tu.AssertEqual(t, VerticalClassOf(r), class)
+	}
+}
+
+func TestLookupVerticalClass(t *testing.T) {
+	circle := Symbol{generateCircle(Pos{30, 30}, 10, 40)}
+	var db Store
+	db.Add('g', circle)
+	db.Add('9', circle)
+	db.Add('q', circle)
+
+	grid := HeightGrid{Ymin: 0, Ymax: 90, Baseline: 60, XHeight: 20, CapHeight: 40, Descender: 20}
+
+	for _, test := range []struct {
+		top, bottom Fl
+		expected    []rune
+	}{
+		{40, 80, []rune{'g', 'q'}}, // descender
+		{20, 60, []rune{'9'}},      // ascender
+		{22, 58, []rune{'9'}},      // tolerance
+	} {
+		input := generateBox(test.top, test.bottom)
+		r, _, _ := db.Lookup(input, grid)
+		tu.Assert(t, strings.ContainsRune(string(test.expected), r))
+
+		candidates := db.LookupN(input, grid, 3)
+		tu.AssertEqual(t, candidates[0].R, r)
+	}
+
+	// without lines, there is no penalty
+	input := generateBox(40, 80)
+	candidates := db.LookupN(input, HeightGrid{Ymin: 0, Ymax: 90, Baseline: 60}, 3)
+	tu.AssertEqual(t, len(candidates), 3)
+	tu.AssertEqual(t, candidates[0].Distance, candidates[2].Distance)
+	tu.AssertEqual(t, candidates[2].ClassPenalty, Fl(1))
+
+	// the penalty is only used to rank the entries : the distances are not modified
+	_, dRaw, _ := db.Lookup(input, HeightGrid{Ymin: 0, Ymax: 90, Baseline: 60})
+	_, d, _ := db.Lookup(input, grid)
+	tu.AssertEqual(t, d, dRaw)
+	candidates = db.LookupN(input, grid, 3)
+	tu.AssertEqual(t, candidates[2].R, '9')
+	tu.AssertEqual(t, candidates[2].Distance, dRaw)
+	tu.Assert(t, candidates[2].ClassPenalty > 1)
+}
+
+func TestClassPenaltyVariants(t *testing.T) {
+	// the penalty uses the variant returned for the entry :
+	// a large o is returned as O, and must not be penalized as a lowercase letter
+	var db Store
+	db.Add('o', Symbol{generateCircle(Pos{30, 30}, 10, 40)})
+	db.Add('D', Symbol{generateEllipse(Pos{30, 30}, 10, 12, 40, 1)})
+
+	grid := HeightGrid{Ymin: 0, Ymax: 90, Baseline: 60, XHeight: 20, CapHeight: 40, Descender: 20}
+	large := generateBox(20, 60)
+	penalties := db.classPenalties(large, grid)
+	tu.AssertEqual(t, penalties, []Fl{1, 1})
+
+	r, _, _ := db.Lookup(large, grid)
+	tu.AssertEqual(t, r, 'O')
+
+	// a small o is still an x-height letter (the entries are sorted : D, o)
+	penalties = db.classPenalties(generateBox(40, 60), grid)
+	tu.Assert(t, penalties[0] > 1)
+	tu.AssertEqual(t, penalties[1], Fl(1))
+}
@@ -10,72 +10,330 @@ import (
 // Store stores the shapes of
 // runes, as setup by the user,
 // and is later used to map a mouse entry to a rune.
+//
+// Several samples may be registered for the same rune : they are
+// then used together in [Store.Lookup], which votes over the closest ones.
+//
+// A store may combine several [Layer]s, in which case only the user
+// layer is modified by the methods of the store.
+//
+// [Symbols] should be modified using the methods of the store, which maintain
+// its invariants. Otherwise, [Store.Reindex] must be called.
 type Store struct {
 	// Symbols acts as a map[rune][]Symbols, but with faster iteration,
+	// and is sorted by rune, then by layer (samples of the same rune are adjacent).
 	Symbols []RuneFootprint
+
+	// K is the number of neighbours used by [Store.Lookup]
+	// to vote for a rune. If zero, [DefaultK] is used.
+	K int
+
+	// Calibration is used to convert distances to confidence values.
+	// It is computed by [Store.Calibrate].
+	Calibration Calibration
+
+	// Rejection is the minimum confidence required by [Store.Lookup]
+	// to return a rune. It is only used for calibrated stores,
+	// and zero disables rejection.
+	Rejection Fl
+
+	// Deslant enables the slant normalization : before comparison, the entries
+	// of each [Layer] are deslanted using the dominant slant of the layer,
+	// and the input using the one of the user layer (see [EstimateSlant]).
+	Deslant bool
+
+	// Rotation bounds the rotation of the input tolerated by [Store.Lookup].
+	// The zero value disables the rotation invariance, see [DefaultRotationBounds]
+	// for a typical setting.
+	Rotation RotationBounds
+
+	// Distance is the metric used to compare the input with the entries.
+	// If nil, [BezierDistance] is used, with [Weights].
+	Distance Distance
+
+	// Weights are the coefficients used by the default metric.
+	// The zero value uses [DefaultBezierWeights], and [Store.TuneWeights]
+	// learns them from the samples of the store.
+	Weights BezierWeights
+
+	// Variants are the groups of runes only distinguished by their size,
+	// like o and O, see [Store.LearnSizes].
+	// If empty, [DefaultSizeVariants] is used.
+	Variants []SizeVariants
+
+	// index is used to speed up lookups, see [Store.Reindex]
+	index *indexCache
 }
 
-// NewStore return a database for the given [symbols].
+// DefaultK is the number of neighbours used by [Store.Lookup]
+// when [Store.K] is not set.
+const DefaultK = 3
+
+// NewStore return a database for the given [symbols],
+// with one sample per rune.
 func NewStore(symbols map[rune]Symbol) Store {
 	out := Store{
 		Symbols: make([]RuneFootprint, 0, len(symbols)),
 	}
 	for r, sy := range symbols {
-		fp := sy.Footprint()
-		out.Symbols = append(out.Symbols, RuneFootprint{fp, r})
+		out.Symbols = append(out.Symbols, newRuneFootprint(r, sy))
+	}
+
+	out.sort()
+	out.Reindex()
+
+	return out
+}
+
+// NewStoreFromSamples return a database for the given [samples],
+// registering every sample provided for each rune.
+func NewStoreFromSamples(samples map[rune][]Symbol) Store {
+	var out Store
+	for r, sys := range samples {
+		for _, sy := range sys {
+			out.Symbols = append(out.Symbols, newRuneFootprint(r, sy))
+		}
 	}
 
 	out.sort()
+	out.Reindex()
 
 	return out
 }
 
+// sort uses a stable sort so that the order of the samples
+// for one rune is preserved
 func (s Store) sort() {
-	sort.Slice(s.Symbols, func(i, j int) bool { return s.Symbols[i].R < s.Symbols[j].R })
+	sort.SliceStable(s.Symbols, func(i, j int) bool {
+		ei, ej := s.Symbols[i], s.Symbols[j]
+		if ei.R != ej.R {
+			return ei.R < ej.R
+		}
+		return ei.Layer < ej.Layer
+	})
+}
+
+// runeRange returns the indices [start, end) of the samples
+// for [r] (in every layer) in [s.Symbols], which is assumed to be sorted
+func (s Store) runeRange(r rune) (start, end int) {
+	start = sort.Search(len(s.Symbols), func(i int) bool { return s.Symbols[i].R >= r })
+	end = sort.Search(len(s.Symbols), func(i int) bool { return s.Symbols[i].R > r })
+	return start, end
+}
+
+// Samples returns the footprints registered for [r],
+// in insertion order, in the layer with the highest precedence.
+func (s Store) Samples(r rune) []Footprint {
+	start, end := s.activeRange(r)
+	out := make([]Footprint, 0, end-start)
+	for _, entry := range s.Symbols[start:end] {
+		out = append(out, entry.Footprint)
+	}
+	return out
+}
+
+// Add registers a new sample for [r] in the user layer, after the
+// existing ones.
+// Note that, once added, the user samples hide the samples of the other layers.
+func (s *Store) Add(r rune, sy Symbol) { s.AddWithInfo(r, sy, nil, HeightGrid{}) }
+
+// AddWithInfo is the same as [Store.Add], also storing
+// the information recorded with the points of [sy], and
+// the [grid] it was drawn in (which may be empty), used by [Store.LearnSizes].
+func (s *Store) AddWithInfo(r rune, sy Symbol, info SymbolInfo, grid HeightGrid) {
+	_, end := s.layerRange(r, UserLayer)
+	s.Symbols = append(s.Symbols, RuneFootprint{})
+	copy(s.Symbols[end+1:], s.Symbols[end:])
+	s.Symbols[end] = newRuneFootprintWithInfo(r, sy, info, grid)
+	s.Reindex()
+}
+
+// AddSamples is the same as calling [Store.Add] for each sample,
+// but only updates the index once.
+func (s *Store) AddSamples(samples []LabelledSymbol) {
+	for _, sample := range samples {
+		s.Symbols = append(s.Symbols, newRuneFootprint(sample.R, sample.Symbol))
+	}
+	// the stable sort keeps the new samples after the existing ones
+	s.sort()
+	s.Reindex()
+}
+
+// Replace removes all the samples registered for [r] in the user layer,
+// and replaces them by [sy].
+func (s *Store) Replace(r rune, sy Symbol) { s.ReplaceWithInfo(r, sy, nil, HeightGrid{}) }
+
+// ReplaceWithInfo is the same as [Store.Replace], also storing
+// the information recorded with the points of [sy], and the [grid] it was drawn in.
+func (s *Store) ReplaceWithInfo(r rune, sy Symbol, info SymbolInfo, grid HeightGrid) {
+	start, end := s.layerRange(r, UserLayer)
+	if start == end { // new rune
+		s.AddWithInfo(r, sy, info, grid)
+		return
+	}
+	s.Symbols[start] = newRuneFootprintWithInfo(r, sy, info, grid)
+	s.Symbols = append(s.Symbols[:start+1], s.Symbols[end:]...)
+	s.Reindex()
+}
+
+// Remove removes all the samples registered for [r] in the user layer,
+// returning the number of samples removed.
+// The samples of the other layers are then used, if any.
+func (s *Store) Remove(r rune) int {
+	start, end := s.layerRange(r, UserLayer)
+	s.Symbols = append(s.Symbols[:start], s.Symbols[end:]...)
+	s.Reindex()
+	return end - start
+}
+
+// RemoveSample removes the [index]-th sample of [r], as returned by [Samples].
+// It returns false if [index] is out of range, or if the sample
+// does not belong to the user layer.
+func (s *Store) RemoveSample(r rune, index int) bool {
+	start, end := s.activeRange(r)
+	if index < 0 || start+index >= end || s.Symbols[start].Layer != UserLayer {
+		return false
+	}
+	s.Symbols = append(s.Symbols[:start+index], s.Symbols[start+index+1:]...)
+	s.Reindex()
+	return true
+}
+
+// Clone returns a copy of the store which is not affected by the later
+// modifications of [s], so that it may be used for lookups in another goroutine
+// while [s] is edited.
+func (s Store) Clone() Store {
+	out := s
+	out.Symbols = append([]RuneFootprint(nil), s.Symbols...)
+	return out
+}
+
+// Runes returns the sorted list of the runes registered in the store.
+func (s Store) Runes() []rune {
+	var out []rune
+	s.Range(func(r rune, _ []RuneFootprint) bool {
+		out = append(out, r)
+		return true
+	})
+	return out
+}
+
+// Range calls [f] sequentially for each rune registered in the store,
+// in increasing order, with its samples (in the layer with the highest precedence).
+// If [f] returns false, Range stops the iteration.
+// The [samples] slice must not be modified.
+func (s Store) Range(f func(r rune, samples []RuneFootprint) bool) {
+	for start := 0; start < len(s.Symbols); {
+		r := s.Symbols[start].R
+		_, next := s.runeRange(r)
+		_, end := s.layerRange(r, s.Symbols[start].Layer)
+		if !f(r, s.Symbols[start:end:end]) {
+			return
+		}
+		start = next
+	}
 }
 
 // NewStoreFromDisk load a store previously saved with
-// [Serialize]
+// [Serialize].
+// Legacy stores, saved in JSON format, are also supported,
+// and will be migrated to the current format on the next call to [Serialize].
 func NewStoreFromDisk(filename string) (Store, error) {
-	f, err := os.Open(filename)
+	data, err := os.ReadFile(filename)
 	if err != nil {
-		return Store{}, fmt.Errorf("opening on-disk store: %s", err)
+		return Store{}, fmt.Errorf("opening on-disk store: %w", err)
 	}
-	defer f.Close()
 
-	var out Store
-	err = json.NewDecoder(f).Decode(&out)
+	out, err := NewStoreFromBytes(data)
 	if err != nil {
 		return Store{}, fmt.Errorf("deserializing on-disk store: %s", err)
 	}
+	return out, nil
+}
+
+// NewStoreFromBytes is the same as [NewStoreFromDisk],
+// for in-memory content (like embedded files).
+func NewStoreFromBytes(data []byte) (Store, error) {
+	out, err := parseStore(data)
+	if err != nil {
+		return Store{}, err
+	}
 
 	out.sort()
+	out.Reindex()
 
 	return out, nil
 }
 
-// Serialize dumps the store into [filename]
+// Serialize dumps the user layer of the store into [filename], using a
+// versioned binary format.
+// The file is first written to a temporary file, which is then renamed,
+// so that an existing store is never partially overwritten.
 func (ss Store) Serialize(filename string) error {
-	f, err := os.Create(filename)
-	if err != nil {
-		return fmt.Errorf("creating on-disk store: %s", err)
-	}
-	enc := json.NewEncoder(f)
-	enc.SetIndent(" ", " ")
-	err = enc.Encode(ss)
+	data := encodeStore(ss.Layer(UserLayer))
+	err := writeFileAtomic(filename, data)
 	if err != nil {
 		return fmt.Errorf("serializing on-disk store: %s", err)
 	}
-	err = f.Close()
-	if err != nil {
-		return fmt.Errorf("closing on-disk store: %s", err)
-	}
 	return nil
 }
 
+// RuneFootprint is one sample of the store.
 type RuneFootprint struct {
 	Footprint Footprint `json:"s"`
 	R         rune      `json:"r"`
+
+	// Symbol is the raw input used to build [Footprint].
+	// It is optional (older stores do not have it), and
+	// is used by [Store.Refit].
+	Symbol Symbol `json:"raw,omitempty"`
+
+	// Info is the optional information (timestamps and pressure)
+	// recorded with [Symbol], and is either empty or aligned with it.
+	Info SymbolInfo `json:"info,omitempty"`
+
+	// Grid is the optional grid [Symbol] was drawn in,
+	// used by [Store.LearnSizes].
+	Grid HeightGrid `json:"grid,omitempty"`
+
+	// Layer is the origin of the sample. It is not serialized,
+	// since only the user layer is saved.
+	Layer Layer `json:"-"`
+}
+
+// newRuneFootprint fits [sy], and keeps the raw data
+func newRuneFootprint(r rune, sy Symbol) RuneFootprint {
+	return RuneFootprint{Footprint: sy.Footprint(), R: r, Symbol: sy}
+}
+
+// newRuneFootprintWithInfo also keeps [info], if it is aligned with [sy], and [grid]
+func newRuneFootprintWithInfo(r rune, sy Symbol, info SymbolInfo, grid HeightGrid) RuneFootprint {
+	out := newRuneFootprint(r, sy)
+	if info.matches(sy) {
+		out.Info = info
+	}
+	out.Grid = grid
+	return out
+}
+
+// Refit builds again the footprint of every entry
+// with raw data, so that improvements in the fitting algorithm
+// may be used without drawing the symbols again.
+// Entries without raw data are left unchanged.
+// It returns the number of entries updated.
+//
+// Note that the calibration is not updated : see [Store.Calibrate].
+func (s *Store) Refit() int {
+	var nb int
+	for i, entry := range s.Symbols {
+		if len(entry.Symbol) == 0 {
+			continue
+		}
+		s.Symbols[i].Footprint = entry.Symbol.Footprint()
+		nb++
+	}
+	s.Reindex()
+	return nb
 }
 
 func (st Store) MarshalJSON() ([]byte, error) { return json.Marshal(st.Symbols) }
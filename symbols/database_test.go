@@ -1,6 +1,7 @@
 package symbols
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"testing"
@@ -24,3 +25,190 @@ func TestSerialize(t *testing.T) {
 	tu.AssertNoErr(t, err)
 	tu.AssertEqual(t, len(db2.Symbols), len(db.Symbols))
 }
+
+func TestSerializePermissions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.pen2latex")
+	var db Store
+	tu.AssertNoErr(t, db.Serialize(path))
+	info, err := os.Stat(path)
+	tu.AssertNoErr(t, err)
+	tu.AssertEqual(t, info.Mode().Perm(), os.FileMode(0o644))
+
+	// an existing file keeps its permissions
+	tu.AssertNoErr(t, os.Chmod(path, 0o600))
+	tu.AssertNoErr(t, db.Serialize(path))
+	info, err = os.Stat(path)
+	tu.AssertNoErr(t, err)
+	tu.AssertEqual(t, info.Mode().Perm(), os.FileMode(0o600))
+}
+
+func TestStoreSamples(t *testing.T) {
+	var db Store
+	for _, group := range symbols {
+		r := rune(group.description[0])
+		for _, sy := range group.symbols {
+			db.Add(r, sy)
+		}
+	}
+	for i := 1; i < len(db.Symbols); i++ {
+		tu.Assert(t, db.Symbols[i-1].R <= db.Symbols[i].R)
+	}
+
+	r := rune(symbols[0].description[0])
+	L := len(db.Samples(r))
+	tu.AssertEqual(t, L, len(symbols[0].symbols))
+
+	tu.Assert(t, db.RemoveSample(r, 0))
+	tu.AssertEqual(t, len(db.Samples(r)), L-1)
+	tu.Assert(t, !db.RemoveSample(r, L))
+	tu.Assert(t, !db.RemoveSample('ÿ', 0))
+
+	// samples order is preserved
+	db2 := NewStoreFromSamples(map[rune][]Symbol{r: symbols[0].symbols})
+	tu.AssertEqual(t, db2.Samples(r)[1:], db.Samples(r))
+}
+
+func TestSerializeSamples(t *testing.T) {
+	samples := map[rune][]Symbol{}
+	for _, group := range symbols {
+		r := rune(group.description[0])
+		samples[r] = group.symbols
+	}
+	db := NewStoreFromSamples(samples)
+	path := filepath.Join(os.TempDir(), "database_samples.pen2latex")
+	err := db.Serialize(path)
+	tu.AssertNoErr(t, err)
+
+	db2, err := NewStoreFromDisk(path)
+	tu.AssertNoErr(t, err)
+	tu.AssertEqual(t, len(db2.Symbols), len(db.Symbols))
+	for _, group := range symbols {
+		r := rune(group.description[0])
+		tu.AssertEqual(t, len(db2.Samples(r)), len(group.symbols))
+	}
+}
+
+func TestLookupKNN(t *testing.T) {
+	// leave one out : each sample is matched against the others
+	for _, k := range []int{1, DefaultK} {
+		for gi, group := range symbols {
+			if len(group.symbols) == 1 { // nothing to compare to
+				continue
+			}
+			expectedRune := rune(group.description[0])
+			for si, symbol := range group.symbols {
+				samples := map[rune][]Symbol{}
+				for gj, other := range symbols {
+					r := rune(other.description[0])
+					for sj, s := range other.symbols {
+						if gi == gj && si == sj {
+							continue
+						}
+						samples[r] = append(samples[r], s)
+					}
+				}
+				db := NewStoreFromSamples(samples)
+				db.K = k
+				got, _, _ := db.Lookup(symbol.Footprint(), HeightGrid{})
+				tu.AssertEqual(t, string(got), string(expectedRune))
+			}
+		}
+	}
+}
+
+func TestRefit(t *testing.T) {
+	db := testStoreAllSamples()
+	path := filepath.Join(os.TempDir(), "database_raw.pen2latex")
+	err := db.Serialize(path)
+	tu.AssertNoErr(t, err)
+
+	db2, err := NewStoreFromDisk(path)
+	tu.AssertNoErr(t, err)
+	for i, entry := range db2.Symbols {
+		tu.AssertEqual(t, entry.Symbol, db.Symbols[i].Symbol)
+	}
+
+	// simulate an old fit
+	expected := db2.Symbols[0].Footprint
+	db2.Symbols[0].Footprint = Footprint{}
+	tu.AssertEqual(t, db2.Refit(), len(db2.Symbols))
+	tu.AssertEqual(t, db2.Symbols[0].Footprint, expected)
+}
+
+func TestLegacyStoreWithoutRaw(t *testing.T) {
+	type legacyEntry struct {
+		Footprint Footprint `json:"s"`
+		R         rune      `json:"r"`
+	}
+	legacy := []legacyEntry{
+		{symbols[0].symbols[0].Footprint(), 'x'},
+		{symbols[1].symbols[0].Footprint(), 't'},
+	}
+	data, err := json.Marshal(legacy)
+	tu.AssertNoErr(t, err)
+	path := filepath.Join(os.TempDir(), "database_legacy.pen2latex")
+	err = os.WriteFile(path, data, os.ModePerm)
+	tu.AssertNoErr(t, err)
+
+	db, err := NewStoreFromDisk(path)
+	tu.AssertNoErr(t, err)
+	tu.AssertEqual(t, len(db.Symbols), 2)
+	tu.AssertEqual(t, db.Symbols[0].Footprint, legacy[1].Footprint)
+	tu.AssertEqual(t, db.Refit(), 0)
+	tu.AssertEqual(t, db.Symbols[0].Footprint, legacy[1].Footprint)
+}
+
+func TestStoreMutations(t *testing.T) {
+	sample := func(i int) Symbol { return symbols[i].symbols[0] }
+	isSorted := func(db Store) bool {
+		for i := 1; i < len(db.Symbols); i++ {
+			if db.Symbols[i-1].R > db.Symbols[i].R {
+				return false
+			}
+		}
+		return true
+	}
+
+	var db Store
+	db.Add('b', sample(0))
+	db.Add('a', sample(1))
+	db.Add('c', sample(2))
+	db.Add('b', sample(3))
+	tu.Assert(t, isSorted(db))
+	tu.AssertEqual(t, db.Runes(), []rune{'a', 'b', 'c'})
+	tu.AssertEqual(t, db.Samples('b'), []Footprint{sample(0).Footprint(), sample(3).Footprint()})
+
+	clone := db.Clone()
+	db.Replace('b', sample(4))
+	tu.AssertEqual(t, clone.Samples('b'), []Footprint{sample(0).Footprint(), sample(3).Footprint()})
+	tu.Assert(t, isSorted(db))
+	tu.AssertEqual(t, db.Samples('b'), []Footprint{sample(4).Footprint()})
+	tu.AssertEqual(t, len(db.Symbols), 3)
+
+	db.Replace('d', sample(5)) // new rune
+	tu.AssertEqual(t, db.Runes(), []rune{'a', 'b', 'c', 'd'})
+
+	tu.AssertEqual(t, db.Remove('c'), 1)
+	tu.AssertEqual(t, db.Remove('c'), 0)
+	tu.Assert(t, isSorted(db))
+	tu.AssertEqual(t, db.Runes(), []rune{'a', 'b', 'd'})
+
+	var seen []int
+	db.Add('a', sample(6))
+	db.Range(func(r rune, samples []RuneFootprint) bool {
+		for _, s := range samples {
+			tu.AssertEqual(t, s.R, r)
+		}
+		seen = append(seen, len(samples))
+		return r < 'b'
+	})
+	tu.AssertEqual(t, seen, []int{2, 1})
+
+	// batch insertion
+	batch := Store{Symbols: append([]RuneFootprint(nil), db.Symbols...)}
+	batch.AddSamples([]LabelledSymbol{{'e', sample(7)}, {'a', sample(8)}, {'e', sample(9)}})
+	db.Add('e', sample(7))
+	db.Add('a', sample(8))
+	db.Add('e', sample(9))
+	tu.AssertEqual(t, batch.Symbols, db.Symbols)
+}
@@ -37,15 +37,57 @@ func angleDiff(a1, a2 Fl) Fl {
 	return abs(a1 - a2)
 }
 
+// BezierWeights are the coefficients used by [BezierDistance]
+// to compare two Bezier curves.
+// They may be learned from samples with [TuneBezierWeights].
+type BezierWeights struct {
+	Derivative    Fl // weight of the difference between the normalized derivatives
+	Curvature     Fl // weight of the difference between the curvatures
+	Points        Fl // weight of the (squared) distance between the points
+	Controls      Fl // weight of the (squared) distance between the control points
+	InnerControls Fl // relative weight of the inner control points (P1 and P2)
+
+	// AnglePenalty is added to the distance ratio when the curves
+	// do not turn the same way
+	AnglePenalty Fl
+	// LinePenalty is added to the distance ratio when only one of
+	// the curves is a line
+	LinePenalty Fl
+}
+
+// DefaultBezierWeights have been tuned experimentally.
+var DefaultBezierWeights = BezierWeights{
+	Derivative:    10,
+	Curvature:     10,
+	Points:        1. / 200,
+	Controls:      1. / 16,
+	InnerControls: 0.05,
+	AnglePenalty:  0.5,
+	LinePenalty:   0.5,
+}
+
+// orDefault returns [DefaultBezierWeights] for the zero value
+func (w BezierWeights) orDefault() BezierWeights {
+	if w == (BezierWeights{}) {
+		return DefaultBezierWeights
+	}
+	return w
+}
+
 // measure how U and V are similar
-func (U Bezier) distance(V Bezier) Fl {
+func (w BezierWeights) distance(U, V Bezier) Fl {
+	return w.curveComponents(U, V).total()
+}
+
+// curveComponents returns the terms of the distance between U and V
+func (w BezierWeights) curveComponents(U, V Bezier) DistanceComponents {
 	// handle points
 	pU, isUPoint := U.IsPoint()
 	pV, isVPoint := V.IsPoint()
 	if isUPoint && isVPoint {
-		return distP(pU, pV)
+		return DistanceComponents{Points: distP(pU, pV), Penalty: 1}
 	} else if isUPoint != isVPoint {
-		return Inf
+		return DistanceComponents{Points: Inf, Penalty: 1}
 	}
 
 	var (
@@ -74,22 +116,26 @@ func (U Bezier) distance(V Bezier) Fl {
 
 	var penalityRatio Fl = 1
 	if distanceAngle := angleDiff(tU, tV); distanceAngle > 120 {
-		penalityRatio += 0.5
+		penalityRatio += w.AnglePenalty
 	}
 	if du, dv := U.diffWithLine(), V.diffWithLine(); du < 0.1 && dv > 0.2 || dv < 0.1 && du > 0.2 {
-		penalityRatio += 0.5
+		penalityRatio += w.LinePenalty
 	}
 
-	distancePointDiff /= 200
-	curvatureDiff *= 10 // to be comparable with the other metrics
+	distancePointDiff *= w.Points
+	curvatureDiff *= w.Curvature // to be comparable with the other metrics
 
 	distanceControls := U.P0.Sub(V.P0).NormSquared() + U.P3.Sub(V.P3).NormSquared() +
-		0.05*(U.P1.Sub(V.P1).NormSquared()+U.P2.Sub(V.P2).NormSquared())
-	distanceControls /= 16
-
-	// fmt.Println("Bezier distance :", derivativeDiff*10, curvatureDiff, distancePointDiff, distanceControls, penalityRatio)
-
-	return (derivativeDiff*10 + curvatureDiff + distancePointDiff + distanceControls) * penalityRatio
+		w.InnerControls*(U.P1.Sub(V.P1).NormSquared()+U.P2.Sub(V.P2).NormSquared())
+	distanceControls *= w.Controls
+
+	return DistanceComponents{
+		Derivative: derivativeDiff * w.Derivative,
+		Curvature:  curvatureDiff,
+		Points:     distancePointDiff,
+		Controls:   distanceControls,
+		Penalty:    penalityRatio,
+	}
 }
 
 func (sh Shape) scale(tr Trans) Shape {
@@ -100,24 +146,17 @@ func (sh Shape) scale(tr Trans) Shape {
 	return out
 }
 
-func shapeDistance(s1, s2 Shape) Fl {
-	s1 = removeSideArtifacts(s1)
-	s2 = removeSideArtifacts(s2)
-
-	tr := mapFromTo(s1.BoundingBox(), s2.BoundingBox())
-	s1 = s1.scale(tr)
+// normalizeShapes maps [s1] to [s2] and rescales both
+// to a reference size, see [normalizingTrans]
+func normalizeShapes(s1, s2 Shape) (Shape, Shape) {
+	tr1, tr2 := normalizingTrans(s1.BoundingBox(), s2.BoundingBox())
+	return s1.scale(tr1), s2.scale(tr2)
+}
 
-	cbox := s1.BoundingBox()
-	h, w := cbox.Height(), cbox.Width()
-	if w < 0.1 { // handle linear sections
-		w = 1
-	}
-	if h < 0.1 { // handle linear sections
-		h = 1
-	}
-	tr = Trans{Scale: 10 / Sqrt(h*w)}
-	s1 = s1.scale(tr)
-	s2 = s2.scale(tr)
+// shapeDistance compares the raw points of two shapes, using the arc length
+// as a third coordinate, so that the drawing order is taken into account.
+func shapeDistance(s1, s2 Shape) Fl {
+	s1, s2 = normalizeShapes(DefaultPipeline.Apply(s1), DefaultPipeline.Apply(s2))
 
 	arcLength1 := pathLengthIndices(s1)
 	arcLength2 := pathLengthIndices(s2)
@@ -146,6 +185,26 @@ func shapeDistance(s1, s2 Shape) Fl {
 	return worstDistance
 }
 
+// normalizingTrans returns the transformations mapping [box1] to [box2],
+// then rescaling both to a reference size, so that
+// distances are comparable
+func normalizingTrans(box1, box2 Rect) (tr1, tr2 Trans) {
+	tr := mapFromTo(box1, box2)
+
+	// use the size of the mapped box1
+	h, w := tr.Scale*box1.Height(), tr.Scale*box1.Width()
+	if w < 0.1 { // handle linear sections
+		w = 1
+	}
+	if h < 0.1 { // handle linear sections
+		h = 1
+	}
+	scale := 10 / Sqrt(h*w)
+	tr1 = Trans{Scale: tr.Scale * scale, Translation: tr.Translation.ScaleTo(scale)}
+	tr2 = Trans{Scale: scale}
+	return tr1, tr2
+}
+
 func dist3(p1 Pos, f1 Fl, p2 Pos, f2 Fl) Fl {
 	return p1.Sub(p2).NormSquared() + (f1-f2)*(f1-f2)
 }
@@ -1,12 +1,87 @@
 package symbols
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 // This file implements algorithms to fit curves to points :
 //	- for a line
 //	- for a cubic bezier curve
 //	- for an union of bezier curves
 
+// FitOptions controls how a [Shape] is segmented into Bezier curves.
+// Start from [DefaultFitOptions] and adjust the fields to tune
+// the fit for a given input device.
+type FitOptions struct {
+	// Preprocessing is applied to the points before fitting.
+	// A nil pipeline uses the points as they are.
+	Preprocessing Pipeline
+
+	// ErrorTolerance is the maximum squared distance between
+	// a point and its fitted curve. Larger values produce fewer curves.
+	ErrorTolerance Fl
+	// ReparameterizeThreshold is the squared error below which the
+	// reparameterization is attempted, before splitting the points.
+	ReparameterizeThreshold Fl
+	// MaxIterations is the number of reparameterization steps.
+	MaxIterations int
+	// CornerAngle is the angle (in degrees) between the tangents
+	// at a split point above which the split is considered as a corner.
+	// Below, the tangents are averaged to get a smooth junction.
+	CornerAngle Fl
+
+	// MergeSegmentAngle and MergeSegmentError are the maximum junction angle (in degrees)
+	// and the maximum average squared error to merge two adjacent curves into a segment.
+	MergeSegmentAngle, MergeSegmentError Fl
+	// MergeCurveAngle and MergeCurveError are the maximum junction angle (in degrees)
+	// and the maximum squared error to merge two adjacent curves into one Bezier curve.
+	MergeCurveAngle, MergeCurveError Fl
+}
+
+// DefaultFitOptions are the options used by [Symbol.Footprint].
+var DefaultFitOptions = FitOptions{
+	Preprocessing:           DefaultPipeline,
+	ErrorTolerance:          5,
+	ReparameterizeThreshold: 50,
+	MaxIterations:           8, // tuned experimentally
+	CornerAngle:             45,
+	MergeSegmentAngle:       10,
+	MergeSegmentError:       1.1,
+	MergeCurveAngle:         5,
+	MergeCurveError:         12,
+}
+
+func (opts FitOptions) validate() error {
+	if opts.ErrorTolerance <= 0 {
+		return fmt.Errorf("invalid error tolerance %g", opts.ErrorTolerance)
+	}
+	if opts.MaxIterations < 0 {
+		return fmt.Errorf("invalid iterations count %d", opts.MaxIterations)
+	}
+	if opts.CornerAngle < 0 || opts.MergeSegmentAngle < 0 || opts.MergeCurveAngle < 0 {
+		return errors.New("invalid negative angle")
+	}
+	return nil
+}
+
+// FitStroke preprocesses and segments [shape] into Bezier curves, using [opts].
+// An error is returned for invalid options, or for a degenerate
+// shape, as reported by [Shape.Validate], before or after preprocessing.
+func FitStroke(shape Shape, opts FitOptions) (Stroke, error) {
+	if err := opts.validate(); err != nil {
+		return Stroke{}, err
+	}
+	if err := shape.Validate(); err != nil {
+		return Stroke{}, err
+	}
+	points := opts.Preprocessing.Apply(shape)
+	if err := Shape(points).Validate(); err != nil {
+		return Stroke{}, fmt.Errorf("after preprocessing: %w", err)
+	}
+	return opts.fitStroke(points), nil
+}
+
 // --------------------------------------------------------------------
 // ----------------------------- Line fit -----------------------------
 // --------------------------------------------------------------------
@@ -82,8 +157,16 @@ func fitSegment(points []Pos) (segment, Fl) {
 // fitCubicBezier implements a gradient descent for fitting ONE cubic bezier curve
 // to the given points, returning the average quadratic error computed by [bezierError]
 //
-// if panics if len(d) < 2
+// a single point is returned as a point curve
 func fitCubicBezier(points []Pos) (Bezier, Fl) {
+	if len(points) < 2 {
+		var p Pos
+		if len(points) == 1 {
+			p = points[0]
+		}
+		return Bezier{p, p, p, p}, 0
+	}
+
 	const maxIterations = 50
 
 	pathLengths := pathLengthIndices(points)
@@ -153,10 +236,11 @@ func bezierEnergyGradient(points []Pos, pathLengths []Fl, bezier Bezier) (out [4
 //
 // and popularized in
 // https://stackoverflow.com/questions/5525665/smoothing-a-hand-drawn-curve/5530600#5530600
-func fitCubicBeziers(points []Pos) []Bezier {
-	// remove artifacts
-	points = removeSideArtifacts(points)
+//
+// [points] is expected to be cleaned up by a [Pipeline] (see [DefaultPipeline]).
+func fitCubicBeziers(points []Pos) []Bezier { return DefaultFitOptions.fitCubicBeziers(points) }
 
+func (opts FitOptions) fitCubicBeziers(points []Pos) []Bezier {
 	if len(points) == 1 {
 		p := points[0]
 		return []Bezier{{p, p, p, p}}
@@ -166,16 +250,11 @@ func fitCubicBeziers(points []Pos) []Bezier {
 	tHat1 := computeStartTangent(points)
 	tHat2 := computeEndTangent(points)
 
-	return fitOrSplitCubicBeziers(points, tHat1, tHat2)
+	return opts.fitOrSplitCubicBeziers(points, tHat1, tHat2)
 }
 
 // points is a subslice of the original shape
-func fitOrSplitCubicBeziers(points []Pos, tHat1, tHat2 Pos) []Bezier {
-	const threshold = 5
-	const iterationThreshold = 50
-
-	const maxIterations = 8 // tuned experimentally
-
+func (opts FitOptions) fitOrSplitCubicBeziers(points []Pos, tHat1, tHat2 Pos) []Bezier {
 	// use heuristic if region only has two points in it
 	if len(points) <= 2 {
 		first, last := points[0], points[len(points)-1]
@@ -190,30 +269,31 @@ func fitOrSplitCubicBeziers(points []Pos, tHat1, tHat2 Pos) []Bezier {
 	currentError, splitPoint := bezierError(points, bezCurve, u)
 
 	// if the error is not too large, refine with reparameterization
-	if currentError < iterationThreshold {
+	if currentError < opts.ReparameterizeThreshold {
 		bestError := currentError
 		bestBezier := bezCurve
-		for i := 0; i < maxIterations; i++ {
+		for i := 0; i < opts.MaxIterations; i++ {
 			u = reparameterize(points, u, bezCurve)
 			bezCurve := inferBezier(points, u, tHat1, tHat2)
 			err, split := bezierError(points, bezCurve, u)
-			if err < bestError {
+			// the Newton steps may diverge, producing invalid curves
+			if err < bestError && bezCurve.isFinite() {
 				bestError = err
 				bestBezier = bezCurve
 				splitPoint = split
 			}
 		}
 
-		if bestError < threshold { // the whole shape is a bezier curve, return early
+		if bestError < opts.ErrorTolerance { // the whole shape is a bezier curve, return early
 			return []Bezier{bestBezier}
 		}
 	}
 
 	// fitting failed: split at max error point and fit recursively
-	tHatCenter1, tHatCenter2 := computeCenterTangent(points, splitPoint)
+	tHatCenter1, tHatCenter2 := computeCenterTangent(points, splitPoint, opts.CornerAngle)
 
-	l1 := fitOrSplitCubicBeziers(points[:splitPoint+1], tHat1, tHatCenter1)
-	l2 := fitOrSplitCubicBeziers(points[splitPoint:], tHatCenter2, tHat2)
+	l1 := opts.fitOrSplitCubicBeziers(points[:splitPoint+1], tHat1, tHatCenter1)
+	l2 := opts.fitOrSplitCubicBeziers(points[splitPoint:], tHatCenter2, tHat2)
 
 	return append(l1, l2...)
 }
@@ -407,7 +487,7 @@ func removeSideArtifacts(points []Pos) []Pos {
 	}
 
 	// smooth edges
-	if len(points) >= 5 {
+	if len(points) >= 6 {
 		L := len(points) - 1
 		for i := 4; i >= 1; i-- {
 			points[L-i] = points[L-i-1].Add(points[L-i+1]).ScaleTo(0.5)
@@ -482,7 +562,8 @@ func havePointsSharpAngle(d []Pos, center int) (bool, Fl) {
 	return err1 < 0.7 && err2 < 0.7, a
 }
 
-func computeCenterTangent(d []Pos, center int) (left, right Pos) {
+// tangents with an angle below [cornerAngle] are averaged
+func computeCenterTangent(d []Pos, center int, cornerAngle Fl) (left, right Pos) {
 	left = computeEndTangent(d[:center+1])
 	right = computeStartTangent(d[center:])
 
@@ -496,7 +577,7 @@ func computeCenterTangent(d []Pos, center int) (left, right Pos) {
 		// if ok, correctedAngle := havePointsSharpAngle(d, center); ok {
 		// 	a = correctedAngle
 		// }
-		smooth := abs(a) < 45
+		smooth := abs(a) < cornerAngle
 		if smooth { // use the average
 			tHatMean := u.Add(v).ScaleTo(0.5)
 
@@ -514,7 +595,11 @@ func computeCenterTangent(d []Pos, center int) (left, right Pos) {
 
 // mergeSimilarCurves post process a Bezier fit to
 // merge adjacent lines and curves which where split during the fit
-func mergeSimilarCurves(curves []Bezier) (out []Bezier) {
+func mergeSimilarCurves(curves []Bezier) []Bezier {
+	return DefaultFitOptions.mergeSimilarCurves(curves)
+}
+
+func (opts FitOptions) mergeSimilarCurves(curves []Bezier) (out []Bezier) {
 	// make sure that points are properly recognized
 	if len(curves) == 1 {
 		if point, ok := curves[0].isAlmostPoint(); ok {
@@ -527,11 +612,6 @@ func mergeSimilarCurves(curves []Bezier) (out []Bezier) {
 		c1, c2 := curves[0], curves[1]
 		if c1.IsRoughlyLinear() && c2.IsRoughlyLinear() && areLinesMerged(c1, c2) {
 			// we got a repetition : only keep the second segment
-
-			if debugMode {
-				fmt.Println("mergeSimilarCurves: removing linear repetition")
-			}
-
 			curves = curves[1:]
 		}
 	}
@@ -563,22 +643,12 @@ func mergeSimilarCurves(curves []Bezier) (out []Bezier) {
 
 		f1, f2, spuriousCurvature := areBeziersSpuriousCurvature(prevCurve, currentCurve)
 
-		if isAligned := angle < 10; isAligned && errSegment < 1.1 {
+		if isAligned := angle < opts.MergeSegmentAngle; isAligned && errSegment < opts.MergeSegmentError {
 			// replace the last element of out
 			out[len(out)-1] = segment{prevCurve.P0, currentCurve.P3}.asBezier()
-
-			if debugMode {
-				fmt.Printf("mergeSimilarCurves: %d -> merging 2 to 1 segment\n", i)
-			}
-
-		} else if isAligned := angle < 5; isAligned && errCurve < 12 && isMergedTangentCompatible {
+		} else if isAligned := angle < opts.MergeCurveAngle; isAligned && errCurve < opts.MergeCurveError && isMergedTangentCompatible {
 			// replace the last element of out
 			out[len(out)-1] = mergedCurve
-
-			if debugMode {
-				fmt.Printf("mergeSimilarCurves: %d -> merging 2 to 1 curve\n", i)
-			}
-
 		} else if spuriousCurvature {
 			// replace the last with the correction...
 			out[len(out)-1] = f1
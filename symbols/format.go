@@ -0,0 +1,373 @@
+package symbols
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// This file implements the on-disk format of a [Store] :
+//	- magic header : "P2LS"
+//	- schema version : uint16
+//	- payload length : uint32
+//	- payload
+//	- CRC32 (IEEE) checksum of the payload : uint32
+//
+// All values are little endian, and float values are stored as float32.
+//
+// Legacy stores, saved as JSON, are detected and loaded transparently.
+
+var storeMagic = [4]byte{'P', '2', 'L', 'S'}
+
+// storeVersion is the current version of the binary format.
+// It should be increased for each change in the payload layout.
+//
+// History :
+//   - 1 : initial version
+//   - 2 : per-point information (timestamps and pressure)
+//   - 3 : weights of the Bezier distance
+//   - 4 : size variants
+//   - 5 : grid of each sample
+const storeVersion uint16 = 5
+
+const headerLength = 4 + 2 + 4
+
+// isBinaryStore returns true if [data] starts with the magic header
+func isBinaryStore(data []byte) bool { return bytes.HasPrefix(data, storeMagic[:]) }
+
+// parseStore detects the format of [data], either binary or legacy JSON.
+func parseStore(data []byte) (Store, error) {
+	if isBinaryStore(data) {
+		return decodeStore(data)
+	}
+	var out Store
+	err := json.Unmarshal(data, &out)
+	return out, err
+}
+
+func encodeStore(st Store) []byte {
+	var w writer
+	w.f32(st.Calibration.Scale)
+	w.weights(st.Weights)
+	w.variants(st.Variants)
+	w.u32(uint32(len(st.Symbols)))
+	for _, entry := range st.Symbols {
+		w.u32(uint32(entry.R))
+		w.footprint(entry.Footprint)
+		w.symbol(entry.Symbol)
+		w.info(entry.Info)
+		w.grid(entry.Grid)
+	}
+	payload := w.Bytes()
+
+	out := make([]byte, headerLength, headerLength+len(payload)+4)
+	copy(out, storeMagic[:])
+	binary.LittleEndian.PutUint16(out[4:], storeVersion)
+	binary.LittleEndian.PutUint32(out[6:], uint32(len(payload)))
+	out = append(out, payload...)
+	out = binary.LittleEndian.AppendUint32(out, crc32.ChecksumIEEE(payload))
+	return out
+}
+
+func decodeStore(data []byte) (Store, error) {
+	if len(data) < headerLength {
+		return Store{}, errors.New("invalid header (EOF)")
+	}
+	version := binary.LittleEndian.Uint16(data[4:])
+	if version == 0 || version > storeVersion {
+		return Store{}, fmt.Errorf("unsupported version %d", version)
+	}
+	payloadLength := int(binary.LittleEndian.Uint32(data[6:]))
+	data = data[headerLength:]
+	if len(data) != payloadLength+4 {
+		return Store{}, fmt.Errorf("invalid payload length (expected %d, got %d)", payloadLength+4, len(data))
+	}
+	payload, checksum := data[:payloadLength], binary.LittleEndian.Uint32(data[payloadLength:])
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return Store{}, errors.New("invalid checksum")
+	}
+
+	r := reader{data: payload}
+	var out Store
+	out.Calibration.Scale = r.f32()
+	if version >= 3 {
+		out.Weights = r.weights()
+	}
+	if version >= 4 {
+		out.Variants = r.variants()
+	}
+	nbEntries := r.u32()
+	out.Symbols = make([]RuneFootprint, 0, r.capacity(nbEntries))
+	for i := uint32(0); i < nbEntries && r.err == nil; i++ {
+		var entry RuneFootprint
+		entry.R = rune(r.u32())
+		entry.Footprint = r.footprint()
+		entry.Symbol = r.symbol()
+		if version >= 2 {
+			entry.Info = r.info()
+		}
+		if version >= 5 {
+			entry.Grid = r.grid()
+		}
+		out.Symbols = append(out.Symbols, entry)
+	}
+	if r.err != nil {
+		return Store{}, r.err
+	}
+	if len(r.data) != 0 {
+		return Store{}, fmt.Errorf("invalid payload (%d unused bytes)", len(r.data))
+	}
+	return out, nil
+}
+
+// writeFileAtomic writes [data] to a temporary file in the same directory
+// as [filename], and then renames it, so that [filename] is never
+// left partially written.
+// The permissions of an existing [filename] are kept, and new files
+// use the usual 0644 (instead of the 0600 of [os.CreateTemp]).
+func writeFileAtomic(filename string, data []byte) error {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+	}
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := f.Name()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(mode)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Rename(tmpName, filename)
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
+
+// writer accumulates little endian values
+type writer struct {
+	bytes.Buffer
+}
+
+func (w *writer) u16(v uint16) { w.Write(binary.LittleEndian.AppendUint16(nil, v)) }
+func (w *writer) u32(v uint32) { w.Write(binary.LittleEndian.AppendUint32(nil, v)) }
+func (w *writer) f32(v Fl)     { w.u32(math.Float32bits(v)) }
+
+func (w *writer) pos(p Pos) {
+	w.f32(p.X)
+	w.f32(p.Y)
+}
+
+func (w *writer) footprint(fp Footprint) {
+	w.u16(uint16(len(fp.Strokes)))
+	for _, stroke := range fp.Strokes {
+		w.u16(uint16(len(stroke.Curves)))
+		for _, cu := range stroke.Curves {
+			w.pos(cu.P0)
+			w.pos(cu.P1)
+			w.pos(cu.P2)
+			w.pos(cu.P3)
+		}
+		w.u16(uint16(len(stroke.ArcLengths)))
+		for _, v := range stroke.ArcLengths {
+			w.f32(v)
+		}
+	}
+}
+
+func (w *writer) weights(bw BezierWeights) {
+	for _, v := range bw.fields() {
+		w.f32(*v)
+	}
+}
+
+func (w *writer) variants(groups []SizeVariants) {
+	w.u16(uint16(len(groups)))
+	for _, group := range groups {
+		w.u16(uint16(len(group)))
+		for _, variant := range group {
+			w.u32(uint32(variant.R))
+			w.f32(variant.Height)
+			w.f32(variant.Bottom)
+		}
+	}
+}
+
+func (w *writer) grid(grid HeightGrid) {
+	for _, v := range grid.fields() {
+		w.f32(*v)
+	}
+}
+
+func (w *writer) symbol(sy Symbol) {
+	w.u16(uint16(len(sy)))
+	for _, shape := range sy {
+		w.u32(uint32(len(shape)))
+		for _, p := range shape {
+			w.pos(p)
+		}
+	}
+}
+
+func (w *writer) info(si SymbolInfo) {
+	w.u16(uint16(len(si)))
+	for _, shape := range si {
+		w.u32(uint32(len(shape)))
+		for _, p := range shape {
+			w.f32(p.Time)
+			w.f32(p.Pressure)
+		}
+	}
+}
+
+// reader reads little endian values,
+// and stores the first error encountered
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) read(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.data) < n {
+		r.err = fmt.Errorf("invalid payload: %s", io.ErrUnexpectedEOF)
+		return nil
+	}
+	out := r.data[:n]
+	r.data = r.data[n:]
+	return out
+}
+
+func (r *reader) u16() uint16 {
+	if b := r.read(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *reader) u32() uint32 {
+	if b := r.read(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *reader) f32() Fl { return math.Float32frombits(r.u32()) }
+
+func (r *reader) pos() Pos { return Pos{X: r.f32(), Y: r.f32()} }
+
+// capacity bounds the allocation for [n] items by the remaining data,
+// so that invalid lengths do not trigger huge allocations
+func (r *reader) capacity(n uint32) int {
+	if int(n) > len(r.data) {
+		return len(r.data)
+	}
+	return int(n)
+}
+
+func (r *reader) footprint() Footprint {
+	nbStrokes := r.u16()
+	out := Footprint{Strokes: make([]Stroke, 0, r.capacity(uint32(nbStrokes)))}
+	for i := uint16(0); i < nbStrokes && r.err == nil; i++ {
+		var stroke Stroke
+		nbCurves := r.u16()
+		stroke.Curves = make([]Bezier, 0, r.capacity(uint32(nbCurves)))
+		for j := uint16(0); j < nbCurves && r.err == nil; j++ {
+			stroke.Curves = append(stroke.Curves, Bezier{r.pos(), r.pos(), r.pos(), r.pos()})
+		}
+		nbArcs := r.u16()
+		stroke.ArcLengths = make([]Fl, 0, r.capacity(uint32(nbArcs)))
+		for j := uint16(0); j < nbArcs && r.err == nil; j++ {
+			stroke.ArcLengths = append(stroke.ArcLengths, r.f32())
+		}
+		out.Strokes = append(out.Strokes, stroke)
+	}
+	return out
+}
+
+func (r *reader) weights() BezierWeights {
+	var out BezierWeights
+	for _, v := range out.fields() {
+		*v = r.f32()
+	}
+	return out
+}
+
+func (r *reader) grid() HeightGrid {
+	var out HeightGrid
+	for _, v := range out.fields() {
+		*v = r.f32()
+	}
+	return out
+}
+
+func (r *reader) variants() []SizeVariants {
+	nbGroups := r.u16()
+	if nbGroups == 0 {
+		return nil
+	}
+	out := make([]SizeVariants, 0, r.capacity(uint32(nbGroups)))
+	for i := uint16(0); i < nbGroups && r.err == nil; i++ {
+		nbVariants := r.u16()
+		group := make(SizeVariants, 0, r.capacity(uint32(nbVariants)))
+		for j := uint16(0); j < nbVariants && r.err == nil; j++ {
+			group = append(group, SizeVariant{R: rune(r.u32()), SizeProfile: SizeProfile{Height: r.f32(), Bottom: r.f32()}})
+		}
+		out = append(out, group)
+	}
+	return out
+}
+
+func (r *reader) symbol() Symbol {
+	nbShapes := r.u16()
+	if nbShapes == 0 {
+		return nil
+	}
+	out := make(Symbol, 0, r.capacity(uint32(nbShapes)))
+	for i := uint16(0); i < nbShapes && r.err == nil; i++ {
+		nbPoints := r.u32()
+		shape := make(Shape, 0, r.capacity(nbPoints))
+		for j := uint32(0); j < nbPoints && r.err == nil; j++ {
+			shape = append(shape, r.pos())
+		}
+		out = append(out, shape)
+	}
+	return out
+}
+
+func (r *reader) info() SymbolInfo {
+	nbShapes := r.u16()
+	if nbShapes == 0 {
+		return nil
+	}
+	out := make(SymbolInfo, 0, r.capacity(uint32(nbShapes)))
+	for i := uint16(0); i < nbShapes && r.err == nil; i++ {
+		nbPoints := r.u32()
+		shape := make(ShapeInfo, 0, r.capacity(nbPoints))
+		for j := uint32(0); j < nbPoints && r.err == nil; j++ {
+			shape = append(shape, PointInfo{Time: r.f32(), Pressure: r.f32()})
+		}
+		out = append(out, shape)
+	}
+	return out
+}
@@ -0,0 +1,135 @@
+package symbols
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tu "github.com/benoitkugler/pen2latex/testutils"
+)
+
+func TestEncodeStore(t *testing.T) {
+	db := testStoreAllSamples()
+	db.Calibration = Calibration{Scale: 12.5}
+	db.Weights = DefaultBezierWeights
+	db.Weights.Curvature = 4
+	db.Variants = []SizeVariants{{{'o', SizeProfile{Height: 0.4}}, {'O', SizeProfile{Height: 0.8, Bottom: 0.1}}}}
+	data := encodeStore(db)
+	tu.Assert(t, isBinaryStore(data))
+
+	db2, err := parseStore(data)
+	tu.AssertNoErr(t, err)
+	tu.AssertEqual(t, db2.Calibration, db.Calibration)
+	tu.AssertEqual(t, db2.Weights, db.Weights)
+	tu.AssertEqual(t, db2.Variants, db.Variants)
+	tu.AssertEqual(t, db2.Symbols, db.Symbols)
+
+	// the binary format is more compact than JSON
+	dataJSON, err := json.Marshal(db)
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, len(data) < len(dataJSON))
+
+	// empty store
+	db3, err := parseStore(encodeStore(Store{}))
+	tu.AssertNoErr(t, err)
+	tu.AssertEqual(t, len(db3.Symbols), 0)
+}
+
+func TestDecodeStoreInvalid(t *testing.T) {
+	data := encodeStore(testStoreAllSamples())
+
+	// truncated writes
+	for _, L := range []int{3, headerLength, len(data) / 2, len(data) - 1} {
+		_, err := parseStore(data[:L])
+		tu.Assert(t, err != nil)
+	}
+
+	// corrupted data
+	corrupted := append([]byte(nil), data...)
+	corrupted[len(corrupted)/2] ^= 0xFF
+	_, err := parseStore(corrupted)
+	tu.Assert(t, err != nil)
+
+	// unknown version
+	future := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint16(future[4:], storeVersion+1)
+	_, err = parseStore(future)
+	tu.Assert(t, err != nil)
+}
+
+func TestMigrateLegacyStore(t *testing.T) {
+	db := testStoreAllSamples()
+	dataJSON, err := json.Marshal(db)
+	tu.AssertNoErr(t, err)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "store.json")
+	err = os.WriteFile(path, dataJSON, os.ModePerm)
+	tu.AssertNoErr(t, err)
+
+	legacy, err := NewStoreFromDisk(path)
+	tu.AssertNoErr(t, err)
+	tu.AssertEqual(t, legacy.Symbols, db.Symbols)
+
+	// saving uses the binary format
+	err = legacy.Serialize(path)
+	tu.AssertNoErr(t, err)
+	data, err := os.ReadFile(path)
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, isBinaryStore(data))
+
+	migrated, err := NewStoreFromDisk(path)
+	tu.AssertNoErr(t, err)
+	tu.AssertEqual(t, migrated.Symbols, db.Symbols)
+
+	// no temporary file is left
+	entries, err := os.ReadDir(dir)
+	tu.AssertNoErr(t, err)
+	tu.AssertEqual(t, len(entries), 1)
+}
+
+func TestEncodeStoreInfo(t *testing.T) {
+	sy := symbols[0].symbols[0]
+	info := make(SymbolInfo, len(sy))
+	for i, shape := range sy {
+		for j := range shape {
+			info[i] = append(info[i], PointInfo{Time: Fl(10 * (i*100 + j)), Pressure: 0.5})
+		}
+	}
+
+	var db Store
+	grid := HeightGrid{Ymin: 0, Ymax: 90, Baseline: 60, XHeight: 20}
+	db.AddWithInfo('a', sy, info, grid)
+	db.AddWithInfo('b', sy, info[:1], HeightGrid{}) // not aligned, ignored
+	tu.AssertEqual(t, db.Symbols[0].Info, info)
+	tu.AssertEqual(t, len(db.Symbols[1].Info), 0)
+
+	db2, err := parseStore(encodeStore(db))
+	tu.AssertNoErr(t, err)
+	tu.AssertEqual(t, db2.Symbols[0].Info, info)
+	tu.AssertEqual(t, len(db2.Symbols[1].Info), 0)
+	tu.AssertEqual(t, db2.Symbols[0].Grid, grid)
+	tu.AssertEqual(t, db2.Symbols[1].Grid, HeightGrid{})
+}
+
+func TestDecodeStoreV1(t *testing.T) {
+	db := NewStore(map[rune]Symbol{'a': symbols[0].symbols[0]})
+
+	// version 1 has no weights, no variants, no info and no grid : remove the (zero) weights,
+	// the (empty) variants, and the (empty) info and (zero) grid of the only entry
+	data := encodeStore(db)
+	payload := data[headerLength : len(data)-4]
+	payload = append(payload[:4:4], payload[4+7*4+2:len(payload)-2-6*4]...)
+	v1 := append([]byte(nil), data[:headerLength]...)
+	binary.LittleEndian.PutUint16(v1[4:], 1)
+	binary.LittleEndian.PutUint32(v1[6:], uint32(len(payload)))
+	v1 = append(v1, payload...)
+	v1 = binary.LittleEndian.AppendUint32(v1, crc32.ChecksumIEEE(payload))
+
+	db2, err := parseStore(v1)
+	tu.AssertNoErr(t, err)
+	tu.AssertEqual(t, db2.Symbols, db.Symbols)
+}
@@ -0,0 +1,385 @@
+package symbols
+
+import (
+	"fmt"
+	"image"
+	"sort"
+
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// This file implements the generation of symbols from font glyphs,
+// so that a starter [Store] is available without drawing every rune.
+//
+// Glyph outlines describe the contour of the (filled) glyph, whereas
+// handwriting is made of center lines. To bridge the gap,
+// each glyph is rasterized, thinned to a one pixel wide skeleton,
+// which is then traced into [Shape]s, trying to mimic the strokes
+// of a pen.
+
+// glyphPPEM is the size (in pixels per em) used to rasterize glyphs,
+// which is also the size of the resulting symbols.
+const glyphPPEM = 96
+
+// NewStoreFromFont builds a [Store] with one entry
+// for each rune in [runes], using the glyphs of [font].
+// Runes without glyph (or with an empty glyph, like spaces) are ignored.
+func NewStoreFromFont(font *sfnt.Font, runes []rune) (Store, error) {
+	symbols := make(map[rune]Symbol, len(runes))
+	var buf sfnt.Buffer
+	for _, r := range runes {
+		sy, err := glyphSymbol(font, &buf, r)
+		if err != nil {
+			return Store{}, err
+		}
+		if len(sy) == 0 {
+			continue
+		}
+		symbols[r] = sy
+	}
+	return NewStore(symbols), nil
+}
+
+// GlyphSymbol returns the [Symbol] corresponding to the glyph
+// used by [font] for [r], or an empty symbol if there is no such glyph.
+// The returned coordinates are in pixels, with the baseline at Y = 0.
+func GlyphSymbol(font *sfnt.Font, r rune) (Symbol, error) {
+	return glyphSymbol(font, &sfnt.Buffer{}, r)
+}
+
+func glyphSymbol(font *sfnt.Font, buf *sfnt.Buffer, r rune) (Symbol, error) {
+	gid, err := font.GlyphIndex(buf, r)
+	if err != nil {
+		return nil, fmt.Errorf("loading glyph for %q: %s", r, err)
+	}
+	if gid == 0 { // no glyph
+		return nil, nil
+	}
+	segments, err := font.LoadGlyph(buf, gid, fixed.I(glyphPPEM), nil)
+	if err != nil {
+		return nil, fmt.Errorf("loading glyph for %q: %s", r, err)
+	}
+
+	bm, origin := rasterize(segments)
+	bm.thin()
+	shapes := bm.trace()
+
+	for i, sh := range shapes {
+		shapes[i] = sh.scale(Trans{Scale: 1, Translation: origin})
+	}
+	sortStrokes(shapes)
+	return shapes, nil
+}
+
+// bitmap is a black and white image
+type bitmap struct {
+	width, height int
+	pixels        []bool // row major
+}
+
+func (bm bitmap) at(x, y int) bool {
+	if x < 0 || y < 0 || x >= bm.width || y >= bm.height {
+		return false
+	}
+	return bm.pixels[y*bm.width+x]
+}
+
+// rasterize fills the outline given by [segments], returning
+// the bitmap and the position of its origin, in font coordinates
+func rasterize(segments sfnt.Segments) (bitmap, Pos) {
+	const margin = 2 // so that the skeleton never touches the border
+
+	bounds := segments.Bounds()
+	minX, minY := bounds.Min.X.Floor()-margin, bounds.Min.Y.Floor()-margin
+	width, height := bounds.Max.X.Ceil()+margin-minX, bounds.Max.Y.Ceil()+margin-minY
+	if len(segments) == 0 {
+		return bitmap{}, Pos{}
+	}
+
+	toPixel := func(p fixed.Point26_6) (Fl, Fl) {
+		return Fl(p.X)/64 - Fl(minX), Fl(p.Y)/64 - Fl(minY)
+	}
+	raster := vector.NewRasterizer(width, height)
+	for _, seg := range segments {
+		x0, y0 := toPixel(seg.Args[0])
+		x1, y1 := toPixel(seg.Args[1])
+		x2, y2 := toPixel(seg.Args[2])
+		switch seg.Op {
+		case sfnt.SegmentOpMoveTo:
+			raster.MoveTo(x0, y0)
+		case sfnt.SegmentOpLineTo:
+			raster.LineTo(x0, y0)
+		case sfnt.SegmentOpQuadTo:
+			raster.QuadTo(x0, y0, x1, y1)
+		case sfnt.SegmentOpCubeTo:
+			raster.CubeTo(x0, y0, x1, y1, x2, y2)
+		}
+	}
+	raster.ClosePath()
+
+	dst := image.NewAlpha(image.Rect(0, 0, width, height))
+	raster.Draw(dst, dst.Bounds(), image.Opaque, image.Point{})
+
+	out := bitmap{width: width, height: height, pixels: make([]bool, width*height)}
+	for i, a := range dst.Pix {
+		out.pixels[i] = a >= 0x80
+	}
+	return out, Pos{Fl(minX), Fl(minY)}
+}
+
+// neighbours returns the 8 neighbours of (x, y),
+// clockwise, starting from the top one.
+func (bm bitmap) neighbours(x, y int) [8]bool {
+	return [8]bool{
+		bm.at(x, y-1), bm.at(x+1, y-1), bm.at(x+1, y), bm.at(x+1, y+1),
+		bm.at(x, y+1), bm.at(x-1, y+1), bm.at(x-1, y), bm.at(x-1, y-1),
+	}
+}
+
+// thin reduces the bitmap to its skeleton, using
+// the Zhang-Suen algorithm.
+//
+// See "A fast parallel algorithm for thinning digital patterns",
+// T. Y. Zhang and C. Y. Suen, 1984
+func (bm bitmap) thin() {
+	var toRemove []int
+	for changed := true; changed; {
+		changed = false
+		for step := 0; step < 2; step++ {
+			toRemove = toRemove[:0]
+			for y := 0; y < bm.height; y++ {
+				for x := 0; x < bm.width; x++ {
+					if bm.at(x, y) && bm.isRemovable(x, y, step) {
+						toRemove = append(toRemove, y*bm.width+x)
+					}
+				}
+			}
+			for _, i := range toRemove {
+				bm.pixels[i] = false
+			}
+			changed = changed || len(toRemove) != 0
+		}
+	}
+}
+
+func (bm bitmap) isRemovable(x, y int, step int) bool {
+	n := bm.neighbours(x, y)
+	var count, transitions int
+	for i, b := range n {
+		if b {
+			count++
+		}
+		if !b && n[(i+1)%8] {
+			transitions++
+		}
+	}
+	if count < 2 || count > 6 || transitions != 1 {
+		return false
+	}
+	p2, p4, p6, p8 := n[0], n[2], n[4], n[6]
+	if step == 0 {
+		return !(p2 && p4 && p6) && !(p4 && p6 && p8)
+	}
+	return !(p2 && p4 && p8) && !(p2 && p6 && p8)
+}
+
+// neighbourOffsets are the offsets of the 8 neighbours,
+// with the 4-connected ones first
+var neighbourOffsets = [8]image.Point{
+	{0, -1}, {1, 0}, {0, 1}, {-1, 0},
+	{1, -1}, {1, 1}, {-1, 1}, {-1, -1},
+}
+
+// trace walks through the pixels of a skeleton, returning
+// a list of paths, in pixel coordinates.
+// Walks start at the extremities, and go as straight as possible,
+// crossing other strokes if needed.
+func (bm bitmap) trace() []Shape {
+	degree := func(x, y int) int {
+		out := 0
+		for _, b := range bm.neighbours(x, y) {
+			if b {
+				out++
+			}
+		}
+		return out
+	}
+
+	// extremities first, then the remaining pixels (loops)
+	var starts, others []image.Point
+	for y := 0; y < bm.height; y++ {
+		for x := 0; x < bm.width; x++ {
+			if !bm.at(x, y) {
+				continue
+			}
+			if degree(x, y) <= 1 {
+				starts = append(starts, image.Pt(x, y))
+			} else {
+				others = append(others, image.Pt(x, y))
+			}
+		}
+	}
+	starts = append(starts, others...)
+
+	owner := make([]int, len(bm.pixels)) // path index + 1, 0 for unvisited
+	var paths [][]image.Point
+	for _, start := range starts {
+		if owner[start.Y*bm.width+start.X] != 0 {
+			continue
+		}
+		path := bm.walk(start, owner, len(paths)+1)
+		if bm.isSpur(path, owner, len(paths)+1) {
+			// keep the pixels visited, but drop the path
+			paths = append(paths, nil)
+			continue
+		}
+		paths = append(paths, path)
+	}
+
+	var out []Shape
+	for _, path := range paths {
+		if len(path) == 0 {
+			continue
+		}
+		out = append(out, smoothPath(path))
+	}
+	return out
+}
+
+// walk follows the skeleton from [start], marking the pixels
+// visited with [id]
+func (bm bitmap) walk(start image.Point, owner []int, id int) []image.Point {
+	// the number of pixels used to estimate the direction
+	const directionLength = 6
+	path := []image.Point{start}
+	owner[start.Y*bm.width+start.X] = id
+	for current := start; ; {
+		var direction Pos
+		if len(path) >= 2 {
+			ref := path[0]
+			if len(path) > directionLength {
+				ref = path[len(path)-directionLength]
+			}
+			direction = unitVector(current.Sub(ref))
+		}
+
+		next, found := image.Point{}, false
+		bestScore := Fl(-2)
+		for _, offset := range neighbourOffsets {
+			p := current.Add(offset)
+			if !bm.at(p.X, p.Y) || owner[p.Y*bm.width+p.X] != 0 {
+				continue
+			}
+			score := dotProduct(direction, unitVector(offset))
+			if score > bestScore {
+				next, found, bestScore = p, true, score
+			}
+		}
+
+		if !found && len(path) >= directionLength {
+			next, found = bm.jumpCrossing(current, direction, owner)
+		}
+
+		if !found {
+			return path
+		}
+		owner[next.Y*bm.width+next.X] = id
+		path = append(path, next)
+		current = next
+	}
+}
+
+// jumpCrossing looks for an unvisited pixel in [direction], starting from [current],
+// so that strokes are continued after crossing another one
+func (bm bitmap) jumpCrossing(current image.Point, direction Pos, owner []int) (image.Point, bool) {
+	// the maximum gap when jumping over a crossing
+	const crossingLength = glyphPPEM / 12
+	// minimum cosinus between the jump and the direction
+	const maxDeviation = 0.9
+
+	var (
+		best         image.Point
+		found        bool
+		bestDistance = Inf
+	)
+	for y := current.Y - crossingLength; y <= current.Y+crossingLength; y++ {
+		for x := current.X - crossingLength; x <= current.X+crossingLength; x++ {
+			if !bm.at(x, y) || owner[y*bm.width+x] != 0 {
+				continue
+			}
+			v := image.Pt(x, y).Sub(current)
+			d := Pos{Fl(v.X), Fl(v.Y)}.Norm()
+			if d > crossingLength || dotProduct(unitVector(v), direction) < maxDeviation {
+				continue
+			}
+			if d < bestDistance {
+				best, found, bestDistance = image.Pt(x, y), true, d
+			}
+		}
+	}
+	return best, found
+}
+
+// isSpur returns true if [path] is a short branch, connected
+// to other paths, typically resulting from the thinning of serifs or corners.
+func (bm bitmap) isSpur(path []image.Point, owner []int, id int) bool {
+	const minLength = glyphPPEM / 10
+	if len(path) >= minLength {
+		return false
+	}
+	for _, p := range path {
+		for _, offset := range neighbourOffsets {
+			q := p.Add(offset)
+			if bm.at(q.X, q.Y) && owner[q.Y*bm.width+q.X] != id {
+				return true
+			}
+		}
+	}
+	// isolated path, like a dot
+	return false
+}
+
+func unitVector(v image.Point) Pos {
+	out := Pos{Fl(v.X), Fl(v.Y)}
+	out.normalize()
+	return out
+}
+
+// smoothPath converts pixel coordinates to positions,
+// averaging consecutive pixels to reduce the staircase effect
+func smoothPath(path []image.Point) Shape {
+	out := make(Shape, len(path))
+	for i := range path {
+		var sum Pos
+		nb := 0
+		for j := i - 1; j <= i+1; j++ {
+			if j < 0 || j >= len(path) {
+				continue
+			}
+			sum = sum.Add(Pos{Fl(path[j].X) + 0.5, Fl(path[j].Y) + 0.5})
+			nb++
+		}
+		out[i] = sum.ScaleTo(1 / Fl(nb))
+	}
+	return out
+}
+
+// sortStrokes orders the shapes like a (western) writer would :
+// from left to right, then from top to bottom, with small shapes (like dots) last.
+func sortStrokes(shapes []Shape) {
+	const tolerance = glyphPPEM / 10
+	isSmall := func(sh Shape) bool { return diameter(sh) < tolerance }
+	sort.SliceStable(shapes, func(i, j int) bool {
+		si, sj := shapes[i], shapes[j]
+		if smallI, smallJ := isSmall(si), isSmall(sj); smallI != smallJ {
+			return smallJ
+		}
+		bi, bj := si.BoundingBox(), sj.BoundingBox()
+		if abs(bi.UL.X-bj.UL.X) >= tolerance {
+			return bi.UL.X < bj.UL.X
+		}
+		return bi.UL.Y < bj.UL.Y
+	})
+}
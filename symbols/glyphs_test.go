@@ -0,0 +1,85 @@
+package symbols
+
+import (
+	"testing"
+
+	tu "github.com/benoitkugler/pen2latex/testutils"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+)
+
+func loadTestFont(t *testing.T) *sfnt.Font {
+	font, err := sfnt.Parse(goregular.TTF)
+	tu.AssertNoErr(t, err)
+	return font
+}
+
+func TestThin(t *testing.T) {
+	// a thick horizontal bar
+	bm := bitmap{width: 30, height: 9, pixels: make([]bool, 30*9)}
+	for y := 2; y < 7; y++ {
+		for x := 2; x < 28; x++ {
+			bm.pixels[y*bm.width+x] = true
+		}
+	}
+	bm.thin()
+	shapes := bm.trace()
+	tu.AssertEqual(t, len(shapes), 1)
+	tu.Assert(t, shapes[0].BoundingBox().Height() <= 1)
+	tu.Assert(t, shapes[0].BoundingBox().Width() >= 15)
+}
+
+func TestGlyphSymbol(t *testing.T) {
+	font := loadTestFont(t)
+	for _, test := range []struct {
+		r         rune
+		nbStrokes int
+	}{
+		{'o', 1},
+		{'l', 1},
+		{'i', 2},
+		{'=', 2},
+		{'+', 2},
+		{'x', 2},
+		{' ', 0},
+	} {
+		sy, err := GlyphSymbol(font, test.r)
+		tu.AssertNoErr(t, err)
+		tu.AssertEqual(t, len(sy), test.nbStrokes)
+	}
+
+	// the dot of the 'i' comes last
+	sy, _ := GlyphSymbol(font, 'i')
+	tu.Assert(t, len(sy[0]) > len(sy[1]))
+
+	// the upper stroke of '=' comes first
+	sy, _ = GlyphSymbol(font, '=')
+	tu.Assert(t, sy[0].BoundingBox().UL.Y < sy[1].BoundingBox().UL.Y)
+
+	// glyphs are placed on the baseline
+	sy, _ = GlyphSymbol(font, 'x')
+	bbox := sy[0].BoundingBox()
+	bbox.Union(sy[1].BoundingBox())
+	tu.Assert(t, abs(bbox.LR.Y) < 4)
+	tu.Assert(t, bbox.UL.Y < -glyphPPEM/3)
+
+	// 'o' is closed
+	sy, _ = GlyphSymbol(font, 'o')
+	tu.Assert(t, distP(sy[0][0], sy[0][len(sy[0])-1]) < 5)
+}
+
+func TestNewStoreFromFont(t *testing.T) {
+	font := loadTestFont(t)
+	runes := []rune("abcdxy+=() ͸") // include a space and an unassigned rune
+	db, err := NewStoreFromFont(font, runes)
+	tu.AssertNoErr(t, err)
+	tu.AssertEqual(t, len(db.Runes()), len(runes)-2)
+
+	// the store recognizes its own glyphs
+	for _, r := range db.Runes() {
+		sy, err := GlyphSymbol(font, r)
+		tu.AssertNoErr(t, err)
+		got, _, _ := db.Lookup(sy.Footprint(), HeightGrid{})
+		tu.AssertEqual(t, got, r)
+	}
+}
@@ -0,0 +1,362 @@
+package symbols
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// This file implements a pre-filtering step for [Store.Lookup] :
+// cheap features are computed once for each entry, and used
+// to discard the entries which can't match, before computing the
+// (expensive) distance between footprints.
+
+// strokeFeatures are cheap features describing a [Stroke]
+type strokeFeatures struct {
+	nbCurves int
+	tangent  Fl // for two curves strokes, the tangent angle at the junction
+	closure  Fl // distance between the start and the end, relative to the size of the stroke
+	chord    Fl // orientation (in degree, in [0, 180[) of the segment from start to end
+}
+
+// entryFeatures are cheap features describing a [Footprint]
+type entryFeatures struct {
+	aspect  Fl // angle (in degree, in [0, 90]) of the diagonal of the control box
+	strokes []strokeFeatures
+}
+
+func newStrokeFeatures(st Stroke) strokeFeatures {
+	if len(st.Curves) == 0 {
+		return strokeFeatures{}
+	}
+	cbox := st.controlBox()
+	size := Max(Max(cbox.Width(), cbox.Height()), 1)
+	start, end := st.Curves[0].P0, st.Curves[len(st.Curves)-1].P3
+	chord := end.Sub(start)
+	orientation := Fl(math.Atan2(float64(chord.Y), float64(chord.X)) * 180 / math.Pi)
+	if orientation < 0 {
+		orientation += 180
+	}
+	if orientation >= 180 {
+		orientation -= 180
+	}
+	var tangent Fl
+	if len(st.Curves) == 2 {
+		tangent = tangentAngle(st.Curves[0], st.Curves[1])
+	}
+	return strokeFeatures{
+		nbCurves: len(st.Curves),
+		tangent:  tangent,
+		closure:  chord.Norm() / size,
+		chord:    orientation,
+	}
+}
+
+func newEntryFeatures(fp Footprint) entryFeatures {
+	out := entryFeatures{strokes: make([]strokeFeatures, len(fp.Strokes))}
+	for i, st := range fp.Strokes {
+		out.strokes[i] = newStrokeFeatures(st)
+	}
+	cbox := fp.controlBox()
+	out.aspect = Fl(math.Atan2(float64(Max(cbox.Height(), 1)), float64(Max(cbox.Width(), 1))) * 180 / math.Pi)
+	return out
+}
+
+// thresholds used to discard candidates; they are chosen
+// large enough to never discard a correct match
+const (
+	maxAspectDiff = 40  // in degree
+	closedStroke  = 0.1 // below, a stroke is considered closed
+	openStroke    = 0.7 // above, a stroke is considered open
+	maxChordDiff  = 60  // in degree, for open strokes
+)
+
+// areGrosslyDifferent mirrors [areGrosslyDifferent]
+// (note that the tangent angle is invariant by scaling and reversing)
+func (u strokeFeatures) areGrosslyDifferent(v strokeFeatures) bool {
+	if u.nbCurves == 1 && v.nbCurves >= 3 {
+		return true
+	}
+	if u.nbCurves == 1 && v.nbCurves == 2 && v.tangent >= 135 {
+		return true
+	}
+	return false
+}
+
+// areStrokesPlausible returns false if the strokes [u] and [v]
+// can't be matched by [distanceFootprintNoScale]
+func areStrokesPlausible(u, v strokeFeatures) bool {
+	if u.areGrosslyDifferent(v) || v.areGrosslyDifferent(u) {
+		return false
+	}
+	// a closed stroke does not match an open one
+	if u.closure < closedStroke && v.closure > openStroke || v.closure < closedStroke && u.closure > openStroke {
+		return false
+	}
+	// compare the orientation of open strokes (which may be reversed)
+	if u.closure > openStroke && v.closure > openStroke {
+		d := abs(u.chord - v.chord)
+		if d > 90 {
+			d = 180 - d
+		}
+		if d > maxChordDiff {
+			return false
+		}
+	}
+	return true
+}
+
+// isPlausible returns false if [input] can't be matched
+// by the entry described by [ef]
+func (ef entryFeatures) isPlausible(input entryFeatures) bool {
+	if len(ef.strokes) != len(input.strokes) {
+		return false
+	}
+	if abs(ef.aspect-input.aspect) > maxAspectDiff {
+		return false
+	}
+	// two strokes symbols may be permuted, see [distanceSymbolsExact]
+	if len(ef.strokes) == 2 {
+		return ef.arePlausibleStrokes(input, 0, 1) || ef.arePlausibleStrokes(input, 1, 0)
+	}
+	return ef.arePlausibleStrokes(input)
+}
+
+// isCompatiblePlausible returns false if [input] can't
+// be the start of the entry described by [ef]
+func (ef entryFeatures) isCompatiblePlausible(input entryFeatures) bool {
+	if len(ef.strokes) <= len(input.strokes) {
+		return false
+	}
+	// only the exact criteria are used here
+	for i, st := range input.strokes {
+		u := ef.strokes[i]
+		if u.areGrosslyDifferent(st) || st.areGrosslyDifferent(u) {
+			return false
+		}
+	}
+	return true
+}
+
+// arePlausibleStrokes compare strokes one by one,
+// using [permutation] for the entry, if given
+func (ef entryFeatures) arePlausibleStrokes(input entryFeatures, permutation ...int) bool {
+	for i, st := range input.strokes {
+		j := i
+		if len(permutation) != 0 {
+			j = permutation[i]
+		}
+		if !areStrokesPlausible(ef.strokes[j], st) {
+			return false
+		}
+	}
+	return true
+}
+
+// entryKey identifies an entry of a [Store], so that
+// the entries modified without calling [Store.Reindex] are detected.
+// Since footprints are not modified in place, comparing the address
+// of the strokes is enough to detect a new footprint.
+type entryKey struct {
+	r         rune
+	layer     Layer
+	strokes   *Stroke
+	nbStrokes int
+}
+
+func newEntryKey(entry RuneFootprint) entryKey {
+	out := entryKey{r: entry.R, layer: entry.Layer, nbStrokes: len(entry.Footprint.Strokes)}
+	if out.nbStrokes != 0 {
+		out.strokes = &entry.Footprint.Strokes[0]
+	}
+	return out
+}
+
+// storeIndex stores the features of each entry of a [Store].
+//
+// Only the cheap features are computed when building the index :
+// the primitives of the entries and the deslanted footprints are
+// computed on first use, so that reindexing after each modification is cheap.
+// An index is safe for concurrent use.
+type storeIndex struct {
+	keys      []entryKey      // aligned with [Store.Symbols]
+	features  []entryFeatures // aligned with [Store.Symbols], computed on [footprints]
+	active    []bool          // aligned with [Store.Symbols], false for entries hidden by another layer
+	byStrokes map[int][]int   // number of strokes -> indices in [Store.Symbols], for active entries
+
+	layers     []Layer         // aligned with [Store.Symbols]
+	footprints []lazyFootprint // aligned with [Store.Symbols]
+
+	deslantOnce sync.Once
+	deslant     *deslantIndex // computed on first use, see [storeIndex.deslanted]
+}
+
+// deslantIndex stores the data used when [Store.Deslant] is true
+type deslantIndex struct {
+	slants     [BaseLayer + 1]Fl // estimated slant of each layer
+	footprints []lazyFootprint   // aligned with [Store.Symbols], using [slants]
+	features   []entryFeatures   // aligned with [Store.Symbols], computed on [footprints]
+}
+
+// lazyFootprint caches the primitives of a footprint,
+// computed on first use
+type lazyFootprint struct {
+	once   sync.Once
+	source Footprint
+	cached Footprint
+}
+
+// get returns [source] with its primitives cached
+func (lf *lazyFootprint) get() Footprint {
+	lf.once.Do(func() { lf.cached = lf.source.withPrimitives() })
+	return lf.cached
+}
+
+func newStoreIndex(entries []RuneFootprint) *storeIndex {
+	out := &storeIndex{
+		keys:       make([]entryKey, len(entries)),
+		features:   make([]entryFeatures, len(entries)),
+		active:     activeEntries(entries),
+		byStrokes:  make(map[int][]int),
+		layers:     make([]Layer, len(entries)),
+		footprints: make([]lazyFootprint, len(entries)),
+	}
+	for i, entry := range entries {
+		out.keys[i] = newEntryKey(entry)
+		out.features[i] = newEntryFeatures(entry.Footprint)
+		out.layers[i] = entry.Layer
+		out.footprints[i].source = entry.Footprint
+		if !out.active[i] {
+			continue
+		}
+		nbStrokes := len(entry.Footprint.Strokes)
+		out.byStrokes[nbStrokes] = append(out.byStrokes[nbStrokes], i)
+	}
+	return out
+}
+
+// deslanted returns the deslanted entries, computing them if needed
+func (idx *storeIndex) deslanted() *deslantIndex {
+	idx.deslantOnce.Do(func() {
+		fps := make([]Footprint, len(idx.footprints))
+		for i := range idx.footprints {
+			fps[i] = idx.footprints[i].source
+		}
+		out := &deslantIndex{
+			slants:     layerSlants(fps, idx.layers),
+			footprints: make([]lazyFootprint, len(fps)),
+			features:   make([]entryFeatures, len(fps)),
+		}
+		for i, fp := range fps {
+			out.footprints[i].source = fp.Transform(Shear(out.slants[idx.layers[i]]))
+			out.features[i] = newEntryFeatures(out.footprints[i].source)
+		}
+		idx.deslant = out
+	})
+	return idx.deslant
+}
+
+// entry returns the footprint of the entry [i], deslanted if [deslant] is true,
+// with its primitives cached
+func (idx *storeIndex) entry(i int, deslant bool) Footprint {
+	if deslant {
+		return idx.deslanted().footprints[i].get()
+	}
+	return idx.footprints[i].get()
+}
+
+// indexCache holds the current index of a [Store], and is
+// updated atomically so that lookups may be performed concurrently
+type indexCache struct {
+	idx atomic.Pointer[storeIndex]
+}
+
+// Reindex updates the internal index used to speed up lookups.
+// It is automatically called by the methods modifying the store,
+// and should only be called after modifying [Store.Symbols] directly.
+func (db *Store) Reindex() {
+	db.index = new(indexCache)
+	db.index.idx.Store(newStoreIndex(db.Symbols))
+}
+
+// getIndex returns the current index, building it again
+// (and caching it) if it is not up to date
+func (db *Store) getIndex() *storeIndex {
+	if db.index == nil { // never indexed
+		return newStoreIndex(db.Symbols)
+	}
+	idx := db.index.idx.Load()
+	if idx.isStale(db.Symbols) {
+		idx = newStoreIndex(db.Symbols)
+		db.index.idx.Store(idx)
+	}
+	return idx
+}
+
+// isStale returns true if [entries] have been modified
+// since the index was built
+func (idx *storeIndex) isStale(entries []RuneFootprint) bool {
+	if len(idx.keys) != len(entries) {
+		return true
+	}
+	for i, entry := range entries {
+		if newEntryKey(entry) != idx.keys[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// allCandidates returns the active entries, and the active entries with
+// more strokes than [input]
+func (idx *storeIndex) allCandidates(entries []RuneFootprint, input Footprint) (exact, compatible []int) {
+	for i, entry := range entries {
+		if !idx.active[i] {
+			continue
+		}
+		exact = append(exact, i)
+		// inspect the symbols in the store with more strokes
+		if len(entry.Footprint.Strokes) > len(input.Strokes) {
+			compatible = append(compatible, i)
+		}
+	}
+	return exact, compatible
+}
+
+// candidates returns the indices of the entries which may match [input],
+// and the ones which may be compatible with [input].
+// An entry is kept if it is plausible for one of the normalized versions
+// of the input, compared to the deslanted entries if [deslanted] is true.
+func (idx *storeIndex) candidates(input matchInput, deslanted bool) (exact, compatible []int) {
+	entriesFeatures := idx.features
+	if deslanted {
+		entriesFeatures = idx.deslanted().features
+	}
+	var features []entryFeatures
+	for _, fp := range input.variants() {
+		features = append(features, newEntryFeatures(fp))
+	}
+	nbStrokes := len(input.fp.Strokes)
+	for _, i := range idx.byStrokes[nbStrokes] {
+		for _, f := range features {
+			if entriesFeatures[i].isPlausible(f) {
+				exact = append(exact, i)
+				break
+			}
+		}
+	}
+	for n, entries := range idx.byStrokes {
+		if n <= nbStrokes {
+			continue
+		}
+		for _, i := range entries {
+			for _, f := range features {
+				if entriesFeatures[i].isCompatiblePlausible(f) {
+					compatible = append(compatible, i)
+					break
+				}
+			}
+		}
+	}
+	return exact, compatible
+}
@@ -0,0 +1,143 @@
+package symbols
+
+import (
+	"testing"
+
+	tu "github.com/benoitkugler/pen2latex/testutils"
+)
+
+func TestIndexSameResults(t *testing.T) {
+	db := testStoreAllSamples()
+
+	var inputs []Footprint
+	for _, group := range symbols {
+		for _, sy := range group.symbols {
+			inputs = append(inputs, sy.Footprint())
+		}
+	}
+	for _, group := range shapes {
+		for _, sh := range group.shapes {
+			inputs = append(inputs, Symbol{sh}.Footprint())
+		}
+	}
+
+	minOf := func(values []Fl) Fl {
+		out := Inf
+		for _, v := range values {
+			out = Min(out, v)
+		}
+		return out
+	}
+
+	for _, input := range inputs {
+		expected, expectedCompatible := db.distancesLinear(input)
+		got, gotCompatible := db.distances(input)
+		tu.AssertEqual(t, db.vote(got), db.vote(expected))
+		tu.AssertEqual(t, minOf(gotCompatible), minOf(expectedCompatible))
+	}
+}
+
+func TestIndexStale(t *testing.T) {
+	db := testStoreAllSamples()
+	fp := db.Symbols[0].Footprint
+	// direct modification, without Reindex
+	db.Symbols = db.Symbols[:1]
+	r, _, _ := db.Lookup(fp, HeightGrid{})
+	tu.AssertEqual(t, r, db.Symbols[0].R)
+
+	// the rebuilt index is cached
+	idx := db.getIndex()
+	tu.AssertEqual(t, len(idx.features), 1)
+	tu.Assert(t, db.getIndex() == idx)
+
+	db.Reindex()
+	tu.AssertEqual(t, len(db.getIndex().features), 1)
+	tu.Assert(t, db.getIndex() != idx)
+
+	// in place replacement, without Reindex
+	db = testStoreAllSamples()
+	circle := Symbol{generateEllipse(Pos{30, 30}, 20, 20, 60, 1)}.Footprint()
+	db.Symbols[0] = RuneFootprint{R: 'o', Footprint: circle}
+	r, d, _ := db.Lookup(circle, HeightGrid{})
+	tu.AssertEqual(t, r, 'o')
+	tu.Assert(t, d < 1e-3)
+}
+
+func TestIndexNormalizedInputs(t *testing.T) {
+	// the filtering uses the same normalization as the matching
+	line := Symbol{{{0, 0}, {0, 25}, {0, 50}}}
+	italic := line.Transform(Shear(-42))
+	var base, user Store
+	base.Add('|', line)
+	user.Add('/', italic)
+	db := NewLayeredStore(base, Store{}, user)
+	db.Deslant = true
+
+	input := italic.Footprint()
+	expected, _ := db.distancesLinear(input)
+	got, _ := db.distances(input)
+	tu.AssertEqual(t, got, expected)
+	for _, d := range got {
+		tu.Assert(t, d < Inf)
+	}
+
+	db.Rotation = RotationBounds{Default: 20}
+	input = line.Transform(Rotation(-20, Pos{0, 25})).Footprint()
+	expected, _ = db.distancesLinear(input)
+	got, _ = db.distances(input)
+	tu.AssertEqual(t, got, expected)
+}
+
+func TestIndexLazy(t *testing.T) {
+	db := testStoreAllSamples()
+	input := symbols[0].symbols[0].Footprint()
+	db.Lookup(input, HeightGrid{})
+	idx := db.getIndex()
+	tu.Assert(t, idx.deslant == nil) // not needed
+
+	db.Deslant = true
+	r1, d1, _ := db.Lookup(input, HeightGrid{})
+	tu.Assert(t, idx.deslant != nil)
+	// same results as an index computed eagerly
+	db.Reindex()
+	db.getIndex().deslanted()
+	r2, d2, _ := db.Lookup(input, HeightGrid{})
+	tu.AssertEqual(t, r1, r2)
+	tu.AssertEqual(t, d1, d2)
+}
+
+func TestIndexFilters(t *testing.T) {
+	db := testStoreAllSamples()
+	total, kept := 0, 0
+	for _, group := range symbols {
+		input := group.symbols[0].Footprint()
+		idx := db.getIndex()
+		exact, _ := idx.candidates(db.prepareInput(idx, input), false)
+		total += len(db.Symbols)
+		kept += len(exact)
+	}
+	tu.Assert(t, kept < total/2)
+}
+
+func BenchmarkLookup(b *testing.B) {
+	db := testStoreAllSamples()
+	var inputs []Footprint
+	for _, group := range symbols {
+		inputs = append(inputs, group.symbols[0].Footprint())
+	}
+
+	b.Run("indexed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, input := range inputs {
+				db.distances(input)
+			}
+		}
+	})
+	b.Run("linear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, input := range inputs {
+				db.distancesLinear(input)
+			}
+		}
+	})
+}
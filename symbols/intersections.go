@@ -0,0 +1,253 @@
+package symbols
+
+import "sort"
+
+// This file implements the computation of the intersection points
+// between Bezier curves, by recursive subdivision : the curves are split
+// until they are small enough to be approximated by their chords.
+
+// Intersection is a crossing point between two curves.
+type Intersection struct {
+	T1, T2 Fl  // the parameters (in [0,1]) of the point on the first and second curve
+	P      Pos // the intersection point
+}
+
+// StrokeIntersection is a crossing point between two curves of one or two strokes.
+type StrokeIntersection struct {
+	// Curve1 and Curve2 are the indices of the curves in the strokes,
+	// with the parameters [Intersection.T1] and [Intersection.T2]
+	Curve1, Curve2 int
+	Intersection
+}
+
+const (
+	// intersectionTolerance is the size (relative to the size of the curves)
+	// under which a curve is approximated by its chord
+	intersectionTolerance = 1e-3
+	// maxIntersectionDepth bounds the recursion, for overlapping curves
+	maxIntersectionDepth = 24
+	// intersectionMerge is the distance (relative to the size of the curves)
+	// under which two intersection points are considered the same
+	intersectionMerge = 1e-2
+	// closingTolerance is the distance (relative to the size of the stroke)
+	// between the end points under which a stroke is considered closed
+	closingTolerance = 0.1
+)
+
+// bezierPart is the restriction of a curve to [t0, t1]
+type bezierPart struct {
+	b      Bezier
+	t0, t1 Fl
+}
+
+func (bp bezierPart) split() (bezierPart, bezierPart) {
+	b1, b2 := bp.b.splitAt(0.5)
+	middle := (bp.t0 + bp.t1) / 2
+	return bezierPart{b1, bp.t0, middle}, bezierPart{b2, middle, bp.t1}
+}
+
+// isSmall returns true if the control box of the curve
+// is smaller than [tol]
+func (b Bezier) isSmall(tol Fl) bool {
+	box := b.controlBox()
+	return box.Width() <= tol && box.Height() <= tol
+}
+
+// Intersections returns the crossing points between [U] and [other],
+// sorted by parameter on [U].
+func (U Bezier) Intersections(other Bezier) []Intersection {
+	box := U.controlBox()
+	box.Union(other.controlBox())
+	size := Max(Max(box.Width(), box.Height()), 1)
+
+	var out []Intersection
+	intersectParts(bezierPart{U, 0, 1}, bezierPart{other, 0, 1}, size*intersectionTolerance, 0, &out)
+	return mergeIntersections(out, size*intersectionMerge)
+}
+
+func intersectParts(p1, p2 bezierPart, tol Fl, depth int, out *[]Intersection) {
+	box1, box2 := p1.b.controlBox(), p2.b.controlBox()
+	// enlarge the boxes so that flat curves (with empty boxes) are handled
+	box1.UL.X, box1.UL.Y, box1.LR.X, box1.LR.Y = box1.UL.X-tol, box1.UL.Y-tol, box1.LR.X+tol, box1.LR.Y+tol
+	if box1.Intersection(box2).IsEmpty() {
+		return
+	}
+
+	small1, small2 := p1.b.isSmall(tol), p2.b.isSmall(tol)
+	if small1 && small2 || depth >= maxIntersectionDepth {
+		if s, u, ok := chordsIntersection(p1.b, p2.b); ok {
+			*out = append(*out, Intersection{
+				T1: p1.t0 + s*(p1.t1-p1.t0),
+				T2: p2.t0 + u*(p2.t1-p2.t0),
+				P:  p1.b.P0.Add(p1.b.P3.Sub(p1.b.P0).ScaleTo(s)),
+			})
+		}
+		return
+	}
+
+	// only split the curves which are not small enough
+	switch {
+	case small1:
+		p21, p22 := p2.split()
+		intersectParts(p1, p21, tol, depth+1, out)
+		intersectParts(p1, p22, tol, depth+1, out)
+	case small2:
+		p11, p12 := p1.split()
+		intersectParts(p11, p2, tol, depth+1, out)
+		intersectParts(p12, p2, tol, depth+1, out)
+	default:
+		p11, p12 := p1.split()
+		p21, p22 := p2.split()
+		intersectParts(p11, p21, tol, depth+1, out)
+		intersectParts(p11, p22, tol, depth+1, out)
+		intersectParts(p12, p21, tol, depth+1, out)
+		intersectParts(p12, p22, tol, depth+1, out)
+	}
+}
+
+// chordsIntersection returns the parameters of the intersection point
+// of the segments [P0, P3] of [b1] and [b2], if any.
+// Parallel segments are considered not crossing.
+func chordsIntersection(b1, b2 Bezier) (s, u Fl, ok bool) {
+	d1, d2 := b1.P3.Sub(b1.P0), b2.P3.Sub(b2.P0)
+	det := crossProduct(d1, d2)
+	if det == 0 {
+		return 0, 0, false
+	}
+	w := b2.P0.Sub(b1.P0)
+	s = crossProduct(w, d2) / det
+	u = crossProduct(w, d1) / det
+	const eps = 1e-4 // accept the end points
+	if s < -eps || s > 1+eps || u < -eps || u > 1+eps {
+		return 0, 0, false
+	}
+	return Min(Max(s, 0), 1), Min(Max(u, 0), 1), true
+}
+
+// mergeIntersections sorts [inters] by T1, and removes the
+// points closer than [tol] from a previous point
+func mergeIntersections(inters []Intersection, tol Fl) []Intersection {
+	sort.Slice(inters, func(i, j int) bool { return inters[i].T1 < inters[j].T1 })
+	var out []Intersection
+	for _, inter := range inters {
+		isDuplicate := false
+		for _, other := range out {
+			if distP(other.P, inter.P) < tol {
+				isDuplicate = true
+				break
+			}
+		}
+		if !isDuplicate {
+			out = append(out, inter)
+		}
+	}
+	return out
+}
+
+// Intersections returns the crossing points between the curves
+// of [fp] and the ones of [other].
+func (fp Stroke) Intersections(other Stroke) []StrokeIntersection {
+	var out []StrokeIntersection
+	for i, c1 := range fp.Curves {
+		for j, c2 := range other.Curves {
+			for _, inter := range c1.Intersections(c2) {
+				out = append(out, StrokeIntersection{Curve1: i, Curve2: j, Intersection: inter})
+			}
+		}
+	}
+	return mergeStrokeIntersections(out, fp.controlBox(), other.controlBox())
+}
+
+// SelfIntersections returns the points where the stroke crosses itself,
+// including the loops inside one curve, with Curve1 <= Curve2.
+// The junctions between consecutive curves are not reported, nor
+// the junction between the end points of a closed stroke, like in o or D.
+func (fp Stroke) SelfIntersections() []StrokeIntersection {
+	if len(fp.Curves) == 0 {
+		return nil
+	}
+	// split the curves into monotonic parts (in X and Y), which
+	// can't intersect themselves
+	type piece struct {
+		bezierPart
+		curve int
+	}
+	var pieces []piece
+	for i, cu := range fp.Curves {
+		ts := []Fl{0, 1}
+		for _, t := range append(cu.criticalPointsX(), cu.criticalPointsY()...) {
+			if 0 < t && t < 1 {
+				ts = append(ts, t)
+			}
+		}
+		sort.Slice(ts, func(a, b int) bool { return ts[a] < ts[b] })
+		for k := 1; k < len(ts); k++ {
+			t0, t1 := ts[k-1], ts[k]
+			if t1-t0 < 1e-4 {
+				continue
+			}
+			pieces = append(pieces, piece{bezierPart{cu.splitBetween(t0, t1), t0, t1}, i})
+		}
+	}
+
+	box := fp.controlBox()
+	size := Max(Max(box.Width(), box.Height()), 1)
+	start, end := fp.Curves[0].P0, fp.Curves[len(fp.Curves)-1].P3
+	isClosed := distP(start, end) < size*closingTolerance
+	var out []StrokeIntersection
+	for a := range pieces {
+		for b := a + 1; b < len(pieces); b++ {
+			p1, p2 := pieces[a], pieces[b]
+			var inters []Intersection
+			intersectParts(p1.bezierPart, p2.bezierPart, size*intersectionTolerance, 0, &inters)
+			for _, inter := range inters {
+				// ignore the junction of consecutive pieces
+				if b == a+1 && distP(inter.P, p1.b.P3) < size*intersectionMerge {
+					continue
+				}
+				// ignore the junction of the end points
+				if isClosed && p1.curve == 0 && p2.curve == len(fp.Curves)-1 &&
+					distP(inter.P, start) < size*closingTolerance && distP(inter.P, end) < size*closingTolerance {
+					continue
+				}
+				out = append(out, StrokeIntersection{Curve1: p1.curve, Curve2: p2.curve, Intersection: inter})
+			}
+		}
+	}
+	return mergeStrokeIntersections(out, box, box)
+}
+
+// mergeStrokeIntersections removes the duplicated points, occuring
+// at the junctions between curves
+func mergeStrokeIntersections(inters []StrokeIntersection, box1, box2 Rect) []StrokeIntersection {
+	box1.Union(box2)
+	tol := Max(Max(box1.Width(), box1.Height()), 1) * intersectionMerge
+	var out []StrokeIntersection
+	for _, inter := range inters {
+		isDuplicate := false
+		for _, other := range out {
+			if distP(other.P, inter.P) < tol {
+				isDuplicate = true
+				break
+			}
+		}
+		if !isDuplicate {
+			out = append(out, inter)
+		}
+	}
+	return out
+}
+
+// Crossings returns the number of crossing points of the footprint,
+// between two strokes or inside one stroke.
+// It is useful to distinguish symbols like x and )(.
+func (sf Footprint) Crossings() int {
+	var out int
+	for i, st := range sf.Strokes {
+		out += len(st.SelfIntersections())
+		for _, other := range sf.Strokes[i+1:] {
+			out += len(st.Intersections(other))
+		}
+	}
+	return out
+}
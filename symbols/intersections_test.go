@@ -0,0 +1,96 @@
+package symbols
+
+import (
+	"testing"
+
+	tu "github.com/benoitkugler/pen2latex/testutils"
+)
+
+func TestBezierIntersections(t *testing.T) {
+	// lines
+	b1 := segment{Pos{}, Pos{30, 30}}.asBezier()
+	b2 := segment{Pos{0, 20}, Pos{20, 0}}.asBezier()
+	inters := b1.Intersections(b2)
+	tu.AssertEqual(t, len(inters), 1)
+	tu.Assert(t, distP(inters[0].P, Pos{10, 10}) < 0.1)
+	tu.Assert(t, distP(b1.pointAt(inters[0].T1), inters[0].P) < 0.1)
+	tu.Assert(t, abs(inters[0].T2-0.5) < 1e-2)
+
+	// an arch crossed twice by a line
+	arch := Bezier{Pos{0, 0}, Pos{0, 40}, Pos{40, 40}, Pos{40, 0}}
+	line := segment{Pos{-10, 15}, Pos{50, 15}}.asBezier()
+	inters = arch.Intersections(line)
+	tu.AssertEqual(t, len(inters), 2)
+	tu.Assert(t, inters[0].T1 < inters[1].T1)
+	for _, inter := range inters {
+		tu.Assert(t, distP(arch.pointAt(inter.T1), inter.P) < 0.1)
+		tu.Assert(t, distP(line.pointAt(inter.T2), inter.P) < 0.1)
+		tu.Assert(t, abs(inter.P.Y-15) < 0.1)
+	}
+	// symmetric
+	tu.AssertEqual(t, len(line.Intersections(arch)), 2)
+
+	// disjoint curves
+	tu.AssertEqual(t, len(arch.Intersections(segment{Pos{-10, 35}, Pos{50, 35}}.asBezier())), 0)
+
+	// overlapping curves have no isolated crossing
+	tu.AssertEqual(t, len(b1.Intersections(b1)), 0)
+}
+
+func TestStrokeIntersections(t *testing.T) {
+	// a cubic with a loop
+	loop := Stroke{Curves: []Bezier{{Pos{0, 0}, Pos{60, 40}, Pos{-20, 40}, Pos{40, 0}}}}
+	self := loop.SelfIntersections()
+	tu.AssertEqual(t, len(self), 1)
+	tu.Assert(t, self[0].T1 < self[0].T2)
+	tu.Assert(t, distP(loop.Curves[0].pointAt(self[0].T1), loop.Curves[0].pointAt(self[0].T2)) < 0.1)
+
+	// the junctions between curves are not reported
+	corner := Stroke{Curves: []Bezier{
+		segment{Pos{0, 0}, Pos{10, 20}}.asBezier(),
+		segment{Pos{10, 20}, Pos{20, 0}}.asBezier(),
+	}}
+	tu.AssertEqual(t, len(corner.SelfIntersections()), 0)
+
+	// horizontal and vertical lines have constant derivatives
+	ell := Stroke{Curves: []Bezier{
+		segment{Pos{0, 0}, Pos{0, 20}}.asBezier(),
+		segment{Pos{0, 20}, Pos{15, 20}}.asBezier(),
+	}}
+	tu.AssertEqual(t, len(ell.SelfIntersections()), 0)
+	tu.AssertEqual(t, Footprint{Strokes: []Stroke{ell}}.Crossings(), 0)
+	cross := Stroke{Curves: []Bezier{
+		segment{Pos{0, 10}, Pos{20, 10}}.asBezier(),
+		segment{Pos{20, 10}, Pos{10, 0}}.asBezier(),
+		segment{Pos{10, 0}, Pos{10, 20}}.asBezier(),
+	}}
+	self = cross.SelfIntersections()
+	tu.AssertEqual(t, len(self), 1)
+	tu.Assert(t, distP(self[0].P, Pos{10, 10}) < 0.1)
+
+	// closed strokes do not cross themselves at their end points
+	square := Stroke{Curves: []Bezier{
+		segment{Pos{0, 0}, Pos{20, 0}}.asBezier(),
+		segment{Pos{20, 0}, Pos{20, 20}}.asBezier(),
+		segment{Pos{20, 20}, Pos{0, 20}}.asBezier(),
+		segment{Pos{0, 20}, Pos{0, 0}}.asBezier(),
+	}}
+	tu.AssertEqual(t, len(square.SelfIntersections()), 0)
+	o := Symbol{generateEllipse(Pos{20, 20}, 10, 15, 40, 1)}.Footprint()
+	tu.AssertEqual(t, o.Crossings(), 0)
+
+	x := Symbol{
+		{{0, 0}, {5, 5}, {10, 10}, {15, 15}, {20, 20}},
+		{{20, 0}, {15, 5}, {10, 10}, {5, 15}, {0, 20}},
+	}.Footprint()
+	tu.AssertEqual(t, x.Crossings(), 1)
+	inters := x.Strokes[0].Intersections(x.Strokes[1])
+	tu.AssertEqual(t, len(inters), 1)
+	tu.Assert(t, distP(inters[0].P, Pos{10, 10}) < 0.1)
+
+	parens := Symbol{
+		generateEllipse(Pos{0, 10}, 8, 10, 20, 0.5).reverse(),
+		generateEllipse(Pos{20, 10}, 8, 10, 20, 0.5),
+	}.Footprint()
+	tu.AssertEqual(t, parens.Crossings(), 0)
+}
@@ -0,0 +1,115 @@
+package symbols
+
+import "sort"
+
+// Layer identifies the origin of an entry of a [Store].
+//
+// A store may combine several layers (see [NewLayeredStore]) : for each rune,
+// only the samples of the layer with the highest precedence are used,
+// so that users may override the shared symbols.
+// Layers with lower values take precedence.
+type Layer uint8
+
+const (
+	// UserLayer contains the personal samples, and is the
+	// only layer modified by the store methods and saved by [Store.Serialize].
+	UserLayer Layer = iota
+	// TeamLayer contains samples shared by a team.
+	TeamLayer
+	// BaseLayer contains the (read-only) samples bundled with the application.
+	BaseLayer
+)
+
+func (l Layer) String() string {
+	switch l {
+	case UserLayer:
+		return "user"
+	case TeamLayer:
+		return "team"
+	case BaseLayer:
+		return "base"
+	default:
+		return "<invalid layer>"
+	}
+}
+
+// NewLayeredStore combines the given stores, tagging their entries with
+// [BaseLayer], [TeamLayer] and [UserLayer].
+// The settings ([Store.K], [Store.Calibration], [Store.Rejection], [Store.Deslant],
+// [Store.Rotation] and [Store.Distance]) are taken from [user].
+func NewLayeredStore(base, team, user Store) Store {
+	out := Store{
+		Symbols:     make([]RuneFootprint, 0, len(base.Symbols)+len(team.Symbols)+len(user.Symbols)),
+		K:           user.K,
+		Calibration: user.Calibration,
+		Rejection:   user.Rejection,
+		Deslant:     user.Deslant,
+		Rotation:    user.Rotation,
+		Distance:    user.Distance,
+		Variants:    user.Variants,
+	}
+	for _, layer := range [...]struct {
+		store Store
+		layer Layer
+	}{{user, UserLayer}, {team, TeamLayer}, {base, BaseLayer}} {
+		for _, entry := range layer.store.Symbols {
+			entry.Layer = layer.layer
+			out.Symbols = append(out.Symbols, entry)
+		}
+	}
+
+	out.sort()
+	out.Reindex()
+
+	return out
+}
+
+// Layer returns a new store with the entries of [layer] only,
+// and the same settings.
+func (s Store) Layer(layer Layer) Store {
+	out := s
+	out.Symbols = nil
+	out.index = nil
+	for _, entry := range s.Symbols {
+		if entry.Layer == layer {
+			out.Symbols = append(out.Symbols, entry)
+		}
+	}
+	out.Reindex()
+	return out
+}
+
+// layerRange returns the indices [start, end) of the samples
+// for [r] in [layer], in [s.Symbols], which is assumed to be sorted
+func (s Store) layerRange(r rune, layer Layer) (start, end int) {
+	start = sort.Search(len(s.Symbols), func(i int) bool {
+		entry := s.Symbols[i]
+		return entry.R > r || entry.R == r && entry.Layer >= layer
+	})
+	end = sort.Search(len(s.Symbols), func(i int) bool {
+		entry := s.Symbols[i]
+		return entry.R > r || entry.R == r && entry.Layer > layer
+	})
+	return start, end
+}
+
+// activeRange returns the indices [start, end) of the samples
+// for [r] in the layer with the highest precedence.
+func (s Store) activeRange(r rune) (start, end int) {
+	start, end = s.runeRange(r)
+	if start == end {
+		return start, end
+	}
+	return s.layerRange(r, s.Symbols[start].Layer)
+}
+
+// activeEntries returns a mask indicating which entries
+// are not hidden by a layer with higher precedence.
+func activeEntries(entries []RuneFootprint) []bool {
+	out := make([]bool, len(entries))
+	for i, entry := range entries {
+		// entries are sorted by rune, then by layer
+		out[i] = i == 0 || entries[i-1].R != entry.R || entries[i-1].Layer == entry.Layer && out[i-1]
+	}
+	return out
+}
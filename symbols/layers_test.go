@@ -0,0 +1,78 @@
+package symbols
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	tu "github.com/benoitkugler/pen2latex/testutils"
+)
+
+func TestLayeredStore(t *testing.T) {
+	sample := func(i int) Symbol { return symbols[i].symbols[0] }
+
+	base := NewStore(map[rune]Symbol{'a': sample(0), 'b': sample(1)})
+	team := NewStore(map[rune]Symbol{'b': sample(2), 'c': sample(3)})
+	user := NewStore(map[rune]Symbol{'c': sample(4)})
+	user.Rejection = 0.2
+
+	db := NewLayeredStore(base, team, user)
+	tu.AssertEqual(t, db.Rejection, Fl(0.2))
+	tu.AssertEqual(t, db.Runes(), []rune{'a', 'b', 'c'})
+	tu.AssertEqual(t, db.Samples('a'), []Footprint{sample(0).Footprint()})
+	tu.AssertEqual(t, db.Samples('b'), []Footprint{sample(2).Footprint()})
+	tu.AssertEqual(t, db.Samples('c'), []Footprint{sample(4).Footprint()})
+
+	var layers []Layer
+	db.Range(func(r rune, samples []RuneFootprint) bool {
+		tu.AssertEqual(t, len(samples), 1)
+		layers = append(layers, samples[0].Layer)
+		return true
+	})
+	tu.AssertEqual(t, layers, []Layer{BaseLayer, TeamLayer, UserLayer})
+
+	// mutations only apply to the user layer
+	tu.Assert(t, !db.RemoveSample('a', 0))
+	tu.AssertEqual(t, db.Remove('a'), 0)
+	db.Add('a', sample(5))
+	tu.AssertEqual(t, db.Samples('a'), []Footprint{sample(5).Footprint()})
+	tu.AssertEqual(t, db.Remove('a'), 1)
+	tu.AssertEqual(t, db.Samples('a'), []Footprint{sample(0).Footprint()}) // base is used again
+	db.Replace('b', sample(6))
+	tu.AssertEqual(t, db.Samples('b'), []Footprint{sample(6).Footprint()})
+	tu.AssertEqual(t, len(db.Symbols), 6)
+
+	// only the user layer is saved
+	file := filepath.Join(os.TempDir(), "database_layered.pen2latex")
+	tu.AssertNoErr(t, db.Serialize(file))
+	saved, err := NewStoreFromDisk(file)
+	tu.AssertNoErr(t, err)
+	tu.AssertEqual(t, saved.Runes(), []rune{'b', 'c'})
+}
+
+func TestLayeredLookup(t *testing.T) {
+	circle := Symbol{generateCircle(Pos{20, 20}, 10, 40)}
+	var line Shape
+	for i := 0; i < 20; i++ {
+		line = append(line, Pos{Fl(i), 0})
+	}
+
+	// hidden samples are ignored
+	base := NewStore(map[rune]Symbol{'a': circle, 'e': circle})
+	user := NewStore(map[rune]Symbol{'a': {line}})
+	db := NewLayeredStore(base, Store{}, user)
+	r, _, _ := db.Lookup(circle.Footprint(), HeightGrid{})
+	tu.AssertEqual(t, r, 'e')
+	candidates := db.LookupN(circle.Footprint(), HeightGrid{}, 5)
+	for _, c := range candidates {
+		tu.Assert(t, c.R != 'a' || c.Distance > 0)
+	}
+
+	// ties are resolved in favor of the layer with the highest precedence
+	team := NewStore(map[rune]Symbol{'c': circle})
+	db = NewLayeredStore(base, team, Store{})
+	r, _, _ = db.Lookup(circle.Footprint(), HeightGrid{})
+	tu.AssertEqual(t, r, 'c')
+	candidates = db.LookupN(circle.Footprint(), HeightGrid{}, 5)
+	tu.AssertEqual(t, candidates[0].R, 'c')
+}
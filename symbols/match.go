@@ -2,16 +2,16 @@ package symbols
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
-const debugMode = false
-
 // [Lookup] performs approximate matching by finding
-// the closest symbol to [input] in the database and returning its rune.
+// the closest symbols to [input] in the database and returning their rune.
 //
-// It will return 0 if the store is empty or if no symbol matches [input].
-// It returns the best error found.
+// It will return 0 if the store is empty or if no symbol matches [input],
+// or if the confidence of the match is below [Store.Rejection].
+// It returns the best error found for the returned rune (or the rejected one).
 // It also returns a bool indicating if the input is compatible with an other
 // input, in the sense that the first strokes are close.
 //
@@ -21,55 +21,279 @@ const debugMode = false
 // we perform the following steps :
 //   - for each [Shape] in the record, segment it into Bezier curves, yielding a [ShapeFootprint]
 //   - for each symbol entry in the database, compute the distance between its footprint and the input
+//   - penalize the entries whose [VerticalClass] does not match the position of the input
+//     in the typographic lines of [context], if they are known
+//   - select the [Store.K] closest entries (using the penalized distances), and vote for a rune,
+//     weighting by the inverse penalized distance
 //   - disambiguate results using the size of the surrounding context
 func (db *Store) Lookup(input Footprint, context HeightGrid) (rune, Fl, bool) {
-	var (
-		bestIndex                            int = -1
-		bestDistance, bestDistanceCompatible     = Inf, Inf
-	)
+	distances, distancesCompatible := db.distances(input)
+	return db.lookup(input, context, distances, distancesCompatible)
+}
 
-	for i, entry := range db.Symbols {
-		distExact := distanceSymbolsExact(entry.Footprint, input)
-		if distExact < bestDistance {
-			bestDistance = distExact
-			bestIndex = i
-		}
-		// inspect the symbols in the store with more strokes
-		if len(entry.Footprint.Strokes) > len(input.Strokes) {
-			if d := distanceSymbolsCompatible(entry.Footprint, input); d < bestDistanceCompatible {
-				bestDistanceCompatible = d
-			}
-		}
+// lookup implements [Store.Lookup], once the distances are computed
+// The penalty of the vertical classes is only used in the vote, so that
+// the returned distance may be compared with the calibration and the compatible distances.
+func (db *Store) lookup(input Footprint, context HeightGrid, distances, distancesCompatible []Fl) (rune, Fl, bool) {
+	var bestDistanceCompatible = Inf
+	for _, d := range distancesCompatible {
+		bestDistanceCompatible = Min(bestDistanceCompatible, d)
 	}
 
-	hasCompatible := bestDistanceCompatible < Inf && bestDistanceCompatible < 2*bestDistance
+	bestIndex := db.vote(penalized(distances, db.classPenalties(input, context)))
 
 	if bestIndex == -1 {
+		hasCompatible := bestDistanceCompatible < Inf
 		return 0, Inf, hasCompatible
 	}
 
-	r, d := db.Symbols[bestIndex].R, bestDistance
-	r = distinguishByContext(input, context, r)
+	r, d := db.Symbols[bestIndex].R, distances[bestIndex]
+	hasCompatible := bestDistanceCompatible < Inf && bestDistanceCompatible < 2*d
+	if db.isRejected(d) {
+		return 0, d, hasCompatible
+	}
+	r = db.distinguishByContext(input, context, r)
 
 	return r, d, hasCompatible
 }
 
+// distances returns the distance between [input] and each entry of the store,
+// and, for the entries with more strokes than [input], the distance restricted to
+// their first strokes (other values are set to Inf).
+// Only the plausible entries, as given by the store index, are compared.
+func (db *Store) distances(input Footprint) (exact, compatible []Fl) {
+	idx := db.getIndex()
+	prepared := db.prepareInput(idx, input)
+	exactCandidates, compatibleCandidates := db.candidates(idx, prepared)
+	return db.distancesFor(idx, prepared, exactCandidates, compatibleCandidates)
+}
+
+// distancesLinear is the same as [distances], but without pre-filtering
+func (db *Store) distancesLinear(input Footprint) (exact, compatible []Fl) {
+	idx := db.getIndex()
+	exactCandidates, compatibleCandidates := idx.allCandidates(db.Symbols, input)
+	return db.distancesFor(idx, db.prepareInput(idx, input), exactCandidates, compatibleCandidates)
+}
+
+// candidates uses the index to select the entries to compare with [input].
+// The filtering is tuned for [BezierDistance] with ordered strokes, so that the other metrics
+// are applied on every entry.
+func (db *Store) candidates(idx *storeIndex, input matchInput) (exact, compatible []int) {
+	if bd, isBezier := db.metric().(BezierDistance); isBezier && !bd.Unordered {
+		return idx.candidates(input, db.Deslant)
+	}
+	return idx.allCandidates(db.Symbols, input.fp)
+}
+
+func (db *Store) distancesFor(idx *storeIndex, prepared matchInput, exactCandidates, compatibleCandidates []int) (exact, compatible []Fl) {
+	exact, compatible = db.newDistances()
+	for _, i := range exactCandidates {
+		exact[i] = db.entryDistance(idx, i, prepared, false)
+	}
+	for _, i := range compatibleCandidates {
+		compatible[i] = db.entryDistance(idx, i, prepared, true)
+	}
+	return exact, compatible
+}
+
+// newDistances returns two slices filled with Inf,
+// with the same length as [db.Symbols]
+func (db *Store) newDistances() (exact, compatible []Fl) {
+	exact, compatible = make([]Fl, len(db.Symbols)), make([]Fl, len(db.Symbols))
+	for i := range db.Symbols {
+		exact[i], compatible[i] = Inf, Inf
+	}
+	return exact, compatible
+}
+
+// Candidate is one of the possible matches returned by [Store.LookupN].
+type Candidate struct {
+	R        rune `json:"rune"`     // the matched rune, after context disambiguation
+	Distance Fl   `json:"distance"` // the distance between the input and the entry
+	Index    int  `json:"index"`    // the index of the matched entry in [Store.Symbols]
+
+	// ClassPenalty is the factor (at least 1) applied to [Distance] to
+	// rank the candidates, when the [VerticalClass] of the rune does not
+	// match the position of the input
+	ClassPenalty Fl `json:"classPenalty"`
+
+	// Confidence is the calibrated confidence of the match, in [0,1],
+	// see [Store.Confidence]
+	Confidence Fl `json:"confidence"`
+
+	// Compatible is true if the entry has more strokes than the input,
+	// and only its first strokes have been matched : the input
+	// may be the beginning of this entry.
+	Compatible bool `json:"compatible"`
+}
+
+// LookupN returns at most [n] candidates for [input], sorted by increasing distance,
+// multiplied by the class penalty (see [Candidate.ClassPenalty]).
+// Each rune appears at most once as an exact match, using its closest sample,
+// and at most once as a compatible (prefix) match.
+// Entries which can't be matched are not included, but
+// candidates are not filtered by [Store.Rejection].
+func (db *Store) LookupN(input Footprint, context HeightGrid, n int) []Candidate {
+	if n <= 0 {
+		return nil
+	}
+	distances, distancesCompatible := db.distances(input)
+	return db.rank(input, context, distances, distancesCompatible, n)
+}
+
+// rank implements [Store.LookupN], once the distances are computed
+func (db *Store) rank(input Footprint, context HeightGrid, distances, distancesCompatible []Fl, n int) []Candidate {
+	penalties := db.classPenalties(input, context)
+
+	type key struct {
+		r          rune
+		compatible bool
+	}
+	best := map[key]int{} // index in out
+	var out []Candidate
+	add := func(index int, d Fl, compatible bool) {
+		if d == Inf {
+			return
+		}
+		r := db.Symbols[index].R
+		if !compatible {
+			r = db.distinguishByContext(input, context, r)
+		}
+		candidate := Candidate{R: r, Distance: d, Index: index, Confidence: db.Confidence(d), Compatible: compatible, ClassPenalty: 1}
+		if !compatible { // the class is only known for exact matches
+			candidate.ClassPenalty = penaltyAt(penalties, index)
+		}
+		k := key{r, compatible}
+		if i, has := best[k]; has {
+			if candidate.score() < out[i].score() {
+				out[i] = candidate
+			}
+			return
+		}
+		best[k] = len(out)
+		out = append(out, candidate)
+	}
+	for i := range db.Symbols {
+		add(i, distances[i], false)
+		add(i, distancesCompatible[i], true)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		ci, cj := out[i], out[j]
+		if si, sj := ci.score(), cj.score(); si != sj {
+			return si < sj
+		}
+		if li, lj := db.Symbols[ci.Index].Layer, db.Symbols[cj.Index].Layer; li != lj {
+			return li < lj
+		}
+		return ci.Index < cj.Index
+	})
+
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// score is the penalized distance used to rank the candidates
+func (ca Candidate) score() Fl { return ca.Distance * ca.ClassPenalty }
+
+// vote selects the [db.K] entries with the smallest (finite) [distances],
+// and returns the index of the closest sample of the rune
+// with the highest score, where each neighbour contributes
+// with the inverse of its distance.
+// It returns -1 if no distance is finite.
+func (db *Store) vote(distances []Fl) int {
+	k := db.K
+	if k <= 0 {
+		k = DefaultK
+	}
+
+	neighbours := make([]int, 0, len(distances))
+	for i, d := range distances {
+		if d < Inf {
+			neighbours = append(neighbours, i)
+		}
+	}
+	if len(neighbours) == 0 {
+		return -1
+	}
+	// ties are resolved in favor of the layer with the highest precedence
+	sort.SliceStable(neighbours, func(i, j int) bool {
+		ni, nj := neighbours[i], neighbours[j]
+		if distances[ni] != distances[nj] {
+			return distances[ni] < distances[nj]
+		}
+		return db.Symbols[ni].Layer < db.Symbols[nj].Layer
+	})
+	if len(neighbours) > k {
+		neighbours = neighbours[:k]
+	}
+
+	// avoid division by zero for perfect matches
+	const epsilon = 1e-6
+	scores := map[rune]Fl{}
+	for _, index := range neighbours {
+		scores[db.Symbols[index].R] += 1 / (distances[index] + epsilon)
+	}
+
+	// neighbours are sorted, so the first sample seen for a rune is the closest,
+	// and ties are resolved in favor of the closest sample
+	bestIndex, bestScore := -1, Fl(-1)
+	for _, index := range neighbours {
+		if score := scores[db.Symbols[index].R]; score > bestScore {
+			bestIndex, bestScore = index, score
+		}
+	}
+	return bestIndex
+}
+
 // ---------------------------------------------------------------------------
 
-// Footprint builds the footprint of the symbol
-func (sy Symbol) Footprint() Footprint { return newSymbolFootprint(sy) }
+// Footprint builds the footprint of the symbol,
+// using [DefaultPipeline] to preprocess the points.
+// It never panics : invalid points and empty shapes are ignored,
+// and single points are kept as point curves.
+// Use [NewFootprint] to detect such degenerate input.
+func (sy Symbol) Footprint() Footprint { return newSymbolFootprint(sy, DefaultFitOptions) }
+
+// FootprintWith is the same as [Symbol.Footprint], but
+// uses [pipeline] to preprocess the points.
+func (sy Symbol) FootprintWith(pipeline Pipeline) Footprint {
+	opts := DefaultFitOptions
+	opts.Preprocessing = pipeline
+	return newSymbolFootprint(sy, opts)
+}
 
 // Stroke stores a simplified representation of one
 // [Shape]
 type Stroke struct {
 	Curves     []Bezier `json:"c"`
 	ArcLengths []Fl     `json:"a"` // between 0 and 1, starts after the first part and ends at 1
+
+	primitives []Primitive // optional cache, see [Footprint.withPrimitives]
+}
+
+func newFp(points Shape) Stroke { return newStroke(points, DefaultFitOptions) }
+
+// newStroke preprocesses [points], then
+// fits Bezier curves, as specified by [opts]
+// Invalid points are ignored, and an empty stroke is returned
+// if there is no point to fit.
+func newStroke(points Shape, opts FitOptions) Stroke {
+	points = opts.Preprocessing.Apply(points.sanitize())
+	if len(points) == 0 {
+		return Stroke{}
+	}
+	return opts.fitStroke(points)
 }
 
-func newFp(points Shape) Stroke {
+// fitStroke fits Bezier curves to the already preprocessed [points],
+// which must not be empty
+func (opts FitOptions) fitStroke(points []Pos) Stroke {
 	// fit and regularize
-	curves := fitCubicBeziers(points)
-	curves = mergeSimilarCurves(curves)
+	curves := opts.fitCubicBeziers(points)
+	curves = opts.mergeSimilarCurves(curves)
 	out := Stroke{Curves: curves}
 
 	// compute arc lengths
@@ -89,7 +313,11 @@ func (fp *Stroke) inferArcLengths() {
 
 	// normalize
 	for i := range arcLengths {
-		arcLengths[i] /= totalLength
+		if totalLength == 0 { // degenerate stroke : use a uniform subdivision
+			arcLengths[i] = Fl(i+1) / Fl(len(arcLengths))
+		} else {
+			arcLengths[i] /= totalLength
+		}
 	}
 	fp.ArcLengths = arcLengths
 }
@@ -105,6 +333,12 @@ func (fp Stroke) reverse() Stroke {
 		out.Curves[Lc-1-i] = Bezier{c.P3, c.P2, c.P1, c.P0}
 	}
 	out.inferArcLengths()
+	if fp.primitives != nil {
+		out.primitives = make([]Primitive, len(fp.primitives))
+		for i, pr := range fp.primitives {
+			out.primitives[len(fp.primitives)-1-i] = pr.reverse(Lc)
+		}
+	}
 	return out
 }
 
@@ -117,7 +351,7 @@ func (fp Stroke) boundingBox() Rect {
 }
 
 func (fp Stroke) controlBox() Rect {
-	re := fp.Curves[0].controlBox()
+	re := EmptyRect()
 	for _, cu := range fp.Curves {
 		re.Union(cu.controlBox())
 	}
@@ -158,6 +392,12 @@ func (fp Stroke) scale(tr Trans) Stroke {
 	for i, c := range fp.Curves {
 		out.Curves[i] = c.Scale(tr)
 	}
+	if fp.primitives != nil {
+		out.primitives = make([]Primitive, len(fp.primitives))
+		for i, pr := range fp.primitives {
+			out.primitives[i] = pr.scale(tr)
+		}
+	}
 	return out
 }
 
@@ -255,11 +495,13 @@ func startEnd(cu []Bezier) Rect {
 }
 
 // rescale U to V
-func distanceFootprints(U, V Stroke) Fl {
+func distanceFootprints(U, V Stroke) Fl { return DefaultBezierWeights.distanceFootprints(U, V) }
+
+func (w BezierWeights) distanceFootprints(U, V Stroke) Fl {
 	tr := mapFromTo(startEnd(U.Curves), startEnd(V.Curves))
 	U = U.scale(tr)
 
-	return distanceFootprintNoScale(U, V)
+	return w.distanceFootprintNoScale(U, V)
 }
 
 // returns true if U starts with a line, followed by a clean
@@ -292,7 +534,16 @@ func areGrosslyDifferent(U, V Stroke) bool {
 
 // distanceFootprintNoScale returns the distance between U and V,
 // without rescaling step
-func distanceFootprintNoScale(U, V Stroke) Fl {
+func (w BezierWeights) distanceFootprintNoScale(U, V Stroke) Fl {
+	return w.strokeDistance(U, V, nil)
+}
+
+// strokeDistance implements [BezierWeights.distanceFootprintNoScale] and,
+// if [details] is not nil, also fills it with the components of the distance.
+func (w BezierWeights) strokeDistance(U, V Stroke, details *DistanceComponents) Fl {
+	if len(U.Curves) == 0 || len(V.Curves) == 0 { // as for the other metrics
+		return Inf
+	}
 	if areGrosslyDifferent(U, V) || areGrosslyDifferent(V, U) {
 		return Inf
 	}
@@ -307,29 +558,40 @@ func distanceFootprintNoScale(U, V Stroke) Fl {
 	if !(cu <= 250 && cv <= 250) && abs(cu-cv) > 30 {
 		penalty += 1
 	}
+	// distinguish round and elongated loops, like o and 0
+	if lu, ok := U.Loop(); ok {
+		if lv, ok := V.Loop(); ok && abs(lu.Ellipse.Aspect()-lv.Ellipse.Aspect()) > maxLoopAspectDiff {
+			penalty += 1
+		}
+	}
 
 	c1s, c2s := adjustFootprints(U, V)
 
 	var (
 		totalDist   Fl
 		totalLength Fl
+		sum         DistanceComponents
 	)
 	for i := range c1s {
 		c1 := c1s[i]
 		c2 := c2s[i]
 
-		d := c1.distance(c2)
+		components := w.curveComponents(c1, c2)
+		d := components.total()
 		length := c1.arcLength()
 
-		if debugMode {
-			fmt.Println("Curve", i, ":", d, " * ", length)
-		}
-
 		totalDist += d * length
 		totalLength += length
+		if details != nil {
+			sum.addScaled(components, length)
+		}
 	}
 
-	return penalty * totalDist / totalLength
+	out := penalty * totalDist / totalLength
+	if details != nil {
+		*details = sum.average(totalLength, out)
+	}
+	return out
 }
 
 // adjustFootprints assume U has been scaled to match V
@@ -368,7 +630,10 @@ func mapBetweenArcLengths(a1, a2 []Fl) (s1, s2 splitMap) {
 		const gapWidth = 0.05
 		v1, v2 := a1[i1], a2[i2]
 		// are the values roughly the same ?
-		if abs(v1-v2) < gapWidth {
+		// (the last values must only be mapped together, so that
+		// both footprints are fully consumed)
+		isLast1, isLast2 := i1 == len(a1)-1, i2 == len(a2)-1
+		if abs(v1-v2) < gapWidth && isLast1 == isLast2 {
 			// nothing to do
 			i1++
 			i2++
@@ -432,14 +697,15 @@ type Footprint struct {
 	Strokes []Stroke
 }
 
-func newSymbolFootprint(sy Symbol) Footprint {
-	strokes := make([]Stroke, len(sy))
-	for i, shape := range sy {
-		strokes[i] = newFp(shape)
+// newSymbolFootprint skips the shapes without valid points
+func newSymbolFootprint(sy Symbol, opts FitOptions) Footprint {
+	strokes := make([]Stroke, 0, len(sy))
+	for _, shape := range sy {
+		if st := newStroke(shape, opts); len(st.Curves) != 0 {
+			strokes = append(strokes, st)
+		}
 	}
-	out := Footprint{Strokes: strokes}
-
-	return out
+	return Footprint{Strokes: strokes}
 }
 
 // controlBox returns the union of the control box of each shape.
@@ -463,32 +729,17 @@ func (sf Footprint) BoundingBox() Rect {
 
 // assuming len(store) > len(input), only compares the first common strokes
 func distanceSymbolsCompatible(store, input Footprint) Fl {
-	nbStrokes := len(input.Strokes)
-
-	// use the same number of components as the input
-	if len(store.Strokes) < nbStrokes { // ignore this entry
-		return Inf
-	}
-	store.Strokes = store.Strokes[:nbStrokes] // only mutate the local [store]
-
-	return distanceSymbolsExact(store, input)
+	return prefixDistance(BezierDistance{}, store, input)
 }
 
-func distanceStrokes(s1, s2 []Stroke) Fl {
+func (w BezierWeights) distanceStrokes(s1, s2 []Stroke) Fl {
 	var totalDistance Fl
 	for i := range s1 {
 		fpU, fpV := s1[i], s2[i]
 
 		// accept a curve drawn in opposite order
-		if debugMode {
-			fmt.Println("Distance between stroke (regular)")
-		}
-		d1 := distanceFootprintNoScale(fpU, fpV)
-
-		if debugMode {
-			fmt.Println("Distance between stroke (reversed)")
-		}
-		d2 := distanceFootprintNoScale(fpU.reverse(), fpV)
+		d1 := w.distanceFootprintNoScale(fpU, fpV)
+		d2 := w.distanceFootprintNoScale(fpU.reverse(), fpV)
 
 		d := Min(d1, d2)
 
@@ -500,7 +751,10 @@ func distanceStrokes(s1, s2 []Stroke) Fl {
 
 // distanceSymbolsExact compare two footprints for whole symbols
 // is always return infinity if the symbols have not the same length
-func distanceSymbolsExact(U, V Footprint) Fl {
+// (see [BezierWeights.distanceSymbolsUnordered] for a more flexible matching)
+func distanceSymbolsExact(U, V Footprint) Fl { return DefaultBezierWeights.distanceSymbolsExact(U, V) }
+
+func (w BezierWeights) distanceSymbolsExact(U, V Footprint) Fl {
 	if len(U.Strokes) != len(V.Strokes) {
 		return Inf
 	}
@@ -519,7 +773,7 @@ func distanceSymbolsExact(U, V Footprint) Fl {
 		Uscaled[i] = s.scale(tr) // apply the scale
 	}
 
-	dist := distanceStrokes(Uscaled, V.Strokes)
+	dist := w.distanceStrokes(Uscaled, V.Strokes)
 
 	// some symbols may be written in any order
 	// to avoid complicating too much, we only try permutation
@@ -527,7 +781,7 @@ func distanceSymbolsExact(U, V Footprint) Fl {
 	if len(Uscaled) == 2 && len(Uscaled[0].Curves) == 1 && len(Uscaled[1].Curves) == 1 &&
 		len(V.Strokes[0].Curves) == 1 && len(V.Strokes[1].Curves) == 1 {
 		permutated := []Stroke{Uscaled[1], Uscaled[0]}
-		if d := distanceStrokes(permutated, V.Strokes); d < dist {
+		if d := w.distanceStrokes(permutated, V.Strokes); d < dist {
 			dist = d
 		}
 	}
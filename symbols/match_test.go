@@ -450,7 +450,7 @@ func Test8(t *testing.T) {
 
 func TestTrivialMatch(t *testing.T) {
 	x := symbols[0].symbols[0]
-	fp1 := newSymbolFootprint(x)
+	fp1 := x.Footprint()
 	err := distanceSymbolsExact(fp1, fp1)
 	tu.Assert(t, almostEqual(err, 0))
 }
@@ -638,7 +638,7 @@ func TestSymbolDistance(t *testing.T) {
 	)
 	for i, g := range symbols {
 		for _, s := range g.symbols {
-			fp := newSymbolFootprint(s)
+			fp := s.Footprint()
 			footprints = append(footprints, fp)
 			groups = append(groups, i)
 		}
@@ -693,11 +693,54 @@ func TestLookup(t *testing.T) {
 	tu.AssertEqual(t, invalid, rune(0))
 }
 
+func TestLookupN(t *testing.T) {
+	base := map[rune]Symbol{}
+	for _, group := range symbols {
+		r := rune(group.description[0])
+		base[r] = group.symbols[0]
+	}
+	db := NewStore(base)
+
+	for _, group := range symbols {
+		expectedRune := rune(group.description[0])
+		for _, symbol := range group.symbols {
+			candidates := db.LookupN(symbol.Footprint(), HeightGrid{}, 3)
+			tu.Assert(t, 1 <= len(candidates) && len(candidates) <= 3)
+			tu.AssertEqual(t, string(candidates[0].R), string(expectedRune))
+			tu.AssertEqual(t, candidates[0].Compatible, false)
+			tu.AssertEqual(t, db.Symbols[candidates[0].Index].R, expectedRune)
+
+			// Lookup agrees with the first exact candidate
+			r, d, _ := db.Lookup(symbol.Footprint(), HeightGrid{})
+			tu.AssertEqual(t, r, candidates[0].R)
+			tu.AssertEqual(t, d, candidates[0].Distance)
+
+			for i := 1; i < len(candidates); i++ {
+				tu.Assert(t, candidates[i-1].Distance <= candidates[i].Distance)
+			}
+		}
+	}
+
+	all := db.LookupN(symbols[0].symbols[0].Footprint(), HeightGrid{}, len(db.Symbols)*2)
+	seen := map[Candidate]bool{}
+	for _, c := range all {
+		key := Candidate{R: c.R, Compatible: c.Compatible}
+		tu.Assert(t, !seen[key])
+		seen[key] = true
+	}
+
+	invalid := db.LookupN(Symbol{{{}}, {{}}, {{}}, {{}}}.Footprint(), HeightGrid{}, 5)
+	tu.AssertEqual(t, len(invalid), 0)
+
+	tu.AssertEqual(t, len(db.LookupN(symbols[0].symbols[0].Footprint(), HeightGrid{}, 0)), 0)
+	tu.AssertEqual(t, len(db.LookupN(symbols[0].symbols[0].Footprint(), HeightGrid{}, -1)), 0)
+}
+
 func TestPrintSymbols(t *testing.T) {
 	i := 0
 	for ng, group := range symbols {
 		for j, s := range group.symbols {
-			symbol := newSymbolFootprint(s)
+			symbol := s.Footprint()
 			var total Shape
 			for _, fp := range symbol.Strokes {
 				total = append(total, generateFootprint(fp.Curves)...)
@@ -746,7 +789,7 @@ func printComparisonDetails(t *testing.T, U, V Stroke, name string) {
 
 func loadStore(t *testing.T) *Store {
 	dbPath, _ := os.UserHomeDir()
-	dbPath = filepath.Join(dbPath, "pen2latex.store.json")
+	dbPath = filepath.Join(dbPath, "pen2latex.store")
 	db, err := NewStoreFromDisk(dbPath)
 	if err != nil {
 		t.Skipf("no user DB : %s", err)
@@ -0,0 +1,315 @@
+package symbols
+
+// This file implements several metrics used to compare footprints,
+// so that their accuracy may be compared on a given [Store].
+
+// Distance measures how much two footprints differ, 0 meaning identical.
+//
+// The values returned by different metrics have no common scale, but
+// [Store.Calibrate] adapts the confidence to the metric used by the store.
+type Distance interface {
+	// Stroke returns the distance between two strokes,
+	// [U] being rescaled to [V].
+	Stroke(U, V Stroke) Fl
+
+	// Symbol returns the distance between two footprints,
+	// [U] being rescaled to [V], or [Inf] if they can't be matched
+	// (typically when they don't have the same number of strokes).
+	Symbol(U, V Footprint) Fl
+}
+
+// BezierDistance compares the Bezier curves of the strokes,
+// after splitting them to match their arc lengths.
+// It is the metric used by default.
+type BezierDistance struct {
+	// Weights are the coefficients used to compare the curves.
+	// The zero value uses [DefaultBezierWeights].
+	Weights BezierWeights
+
+	// Unordered enables the matching of symbols written with a different
+	// stroke order, or a different number of pen lifts : the best correspondence
+	// between the strokes is searched, allowing one stroke to be matched with two.
+	Unordered bool
+
+	// Budget bounds the number of partial correspondences explored
+	// when [Unordered] is true. If zero, [DefaultAssignmentBudget] is used.
+	Budget int
+}
+
+func (bd BezierDistance) Stroke(U, V Stroke) Fl {
+	return bd.Weights.orDefault().distanceFootprints(U, V)
+}
+
+func (bd BezierDistance) Symbol(U, V Footprint) Fl {
+	if bd.Unordered {
+		return bd.Weights.orDefault().distanceSymbolsUnordered(U, V, bd.Budget)
+	}
+	return bd.Weights.orDefault().distanceSymbolsExact(U, V)
+}
+
+// defaultSampling is the number of points used
+// by [DTWDistance] and [PointCloudDistance] when not specified
+const defaultSampling = 32
+
+// DTWDistance uses Dynamic Time Warping on points
+// resampled along the strokes.
+// Strokes drawn in the opposite direction are accepted, but
+// footprints with empty strokes are never matched.
+type DTWDistance struct {
+	// Points is the number of points sampled on each stroke.
+	// If zero, 32 is used.
+	Points int
+}
+
+func (dd DTWDistance) points() int {
+	if dd.Points <= 0 {
+		return defaultSampling
+	}
+	return dd.Points
+}
+
+func (dd DTWDistance) Stroke(U, V Stroke) Fl {
+	return dd.Symbol(Footprint{Strokes: []Stroke{U}}, Footprint{Strokes: []Stroke{V}})
+}
+
+func (dd DTWDistance) Symbol(U, V Footprint) Fl {
+	if len(U.Strokes) != len(V.Strokes) || len(U.Strokes) == 0 {
+		return Inf
+	}
+	if U.hasEmptyStroke() || V.hasEmptyStroke() {
+		return Inf
+	}
+
+	// use the same scaling for each stroke
+	tr1, tr2 := normalizingTrans(U.controlBox(), V.controlBox())
+	us, vs := make([]Shape, len(U.Strokes)), make([]Shape, len(V.Strokes))
+	for i := range U.Strokes {
+		us[i] = U.Strokes[i].sample(dd.points()).scale(tr1)
+		vs[i] = V.Strokes[i].sample(dd.points()).scale(tr2)
+	}
+
+	strokesDistance := func(us []Shape) Fl {
+		var total Fl
+		for i, u := range us {
+			total += Min(dtw(u, vs[i]), dtw(u.reverse(), vs[i]))
+		}
+		return total / Fl(len(us))
+	}
+
+	d := strokesDistance(us)
+	// as for [BezierDistance], two strokes may be written in any order
+	if len(us) == 2 {
+		d = Min(d, strokesDistance([]Shape{us[1], us[0]}))
+	}
+	return d
+}
+
+// dtw returns the cost of the best alignment between [u] and [v],
+// normalized by the number of points
+func dtw(u, v Shape) Fl {
+	// only keep two rows of the cost matrix
+	previous, current := make([]Fl, len(v)+1), make([]Fl, len(v)+1)
+	for j := range previous {
+		previous[j] = Inf
+	}
+	previous[0] = 0
+	for i := 1; i <= len(u); i++ {
+		current[0] = Inf
+		for j := 1; j <= len(v); j++ {
+			best := Min(previous[j-1], Min(previous[j], current[j-1]))
+			current[j] = distP(u[i-1], v[j-1]) + best
+		}
+		previous, current = current, previous
+	}
+	return previous[len(v)] / Fl(len(u)+len(v))
+}
+
+// PointCloudDistance matches the points of the symbols as
+// clouds, ignoring the order and the direction of the strokes,
+// as done by the $P recognizer :
+//
+// Gestures as Point Clouds: A $P Recognizer for User Interface Prototypes
+// by Radu-Daniel Vatavu, Lisa Anthony and Jacob O. Wobbrock, 2012
+//
+// The points are normalized as in [shapeDistance], but are then matched
+// one to one : the arc length used by [shapeDistance] would reintroduce
+// the drawing order, and its nearest point search lets several points
+// share the same match.
+//
+// Contrary to the $P recognizer, the footprints must have the same number
+// of strokes, see [PointCloudDistance.Symbol]. Empty strokes are ignored.
+type PointCloudDistance struct {
+	// Points is the number of points sampled on each symbol.
+	// If zero, 32 is used.
+	Points int
+}
+
+func (pc PointCloudDistance) points() int {
+	if pc.Points <= 0 {
+		return defaultSampling
+	}
+	return pc.Points
+}
+
+func (pc PointCloudDistance) Stroke(U, V Stroke) Fl {
+	return pc.Symbol(Footprint{Strokes: []Stroke{U}}, Footprint{Strokes: []Stroke{V}})
+}
+
+// Symbol only compares footprints with the same number of strokes,
+// for consistency with the other metrics.
+func (pc PointCloudDistance) Symbol(U, V Footprint) Fl {
+	if len(U.Strokes) != len(V.Strokes) || len(U.Strokes) == 0 {
+		return Inf
+	}
+
+	u, v := U.cloud(pc.points()), V.cloud(pc.points())
+	if len(u) == 0 || len(v) == 0 { // only empty strokes
+		return Inf
+	}
+	u, v = normalizeShapes(u, v)
+
+	// try several starting points, as advised in the $P paper
+	step := int(Sqrt(Fl(len(u))))
+	best := Inf
+	for start := 0; start < len(u); start += step {
+		best = Min(best, cloudDistance(u, v, start))
+		best = Min(best, cloudDistance(v, u, start))
+	}
+	return best / Fl(len(u))
+}
+
+// cloudDistance greedily matches each point of [u], starting at [start], to
+// the closest unmatched point of [v]. The first matches are weighted
+// more, since they are more reliable.
+// [u] and [v] must have the same length.
+func cloudDistance(u, v Shape, start int) Fl {
+	matched := make([]bool, len(v))
+	var total Fl
+	for k := 0; k < len(u); k++ {
+		p := u[(start+k)%len(u)]
+		bestIndex, bestDistance := -1, Inf
+		for j, q := range v {
+			if matched[j] {
+				continue
+			}
+			if d := distP(p, q); d < bestDistance {
+				bestIndex, bestDistance = j, d
+			}
+		}
+		matched[bestIndex] = true
+		weight := 1 - Fl(k)/Fl(len(u))
+		total += weight * bestDistance
+	}
+	return total
+}
+
+// sample returns [n] points evenly spaced along the stroke,
+// or nil for an empty stroke
+func (fp Stroke) sample(n int) Shape {
+	if len(fp.Curves) == 0 {
+		return nil
+	}
+	var points Shape
+	for _, cu := range fp.Curves {
+		points = append(points, cu.toPoints()...)
+	}
+	points = append(points, fp.Curves[len(fp.Curves)-1].P3)
+	return resampleN(points, n)
+}
+
+// hasEmptyStroke returns true if one of the strokes has no curves
+func (sf Footprint) hasEmptyStroke() bool {
+	for _, st := range sf.Strokes {
+		if len(st.Curves) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// cloud returns [n] points sampled on the strokes, each stroke
+// receiving a number of points proportional to its length.
+// Empty strokes are ignored, so that the result is empty if all the strokes are empty.
+func (sf Footprint) cloud(n int) Shape {
+	strokes, _ := nonEmptyStrokes(sf.Strokes)
+	lengths := make([]Fl, len(strokes))
+	var total Fl
+	for i, st := range strokes {
+		for _, cu := range st.Curves {
+			lengths[i] += cu.arcLength()
+		}
+		total += lengths[i]
+	}
+
+	out := make(Shape, 0, n)
+	var covered Fl
+	for i, st := range strokes {
+		covered += lengths[i]
+		// use the cumulated lengths so that the total is exactly n
+		end := n
+		if i != len(strokes)-1 && total > 0 {
+			end = int(Fl(n) * covered / total)
+		}
+		if count := end - len(out); count > 0 {
+			out = append(out, st.sample(count)...)
+		}
+	}
+	return out
+}
+
+// resampleN returns [n] points evenly spaced along the path
+// described by [points], which must not be empty
+func resampleN(points []Pos, n int) Shape {
+	out := make(Shape, n)
+	lengths := pathLengths(points)
+	total := lengths[len(lengths)-1]
+	j := 0 // current segment
+	for i := range out {
+		target := total / 2
+		if n > 1 {
+			target = total * Fl(i) / Fl(n-1)
+		}
+		for j < len(points)-2 && lengths[j+1] < target {
+			j++
+		}
+		if j+1 >= len(points) { // single point
+			out[i] = points[0]
+			continue
+		}
+		segment := lengths[j+1] - lengths[j]
+		var t Fl
+		if segment > 0 {
+			t = Min(Max((target-lengths[j])/segment, 0), 1)
+		}
+		out[i] = points[j].Add(points[j+1].Sub(points[j]).ScaleTo(t))
+	}
+	return out
+}
+
+// reverse returns a new shape with the points in the opposite order
+func (sh Shape) reverse() Shape {
+	out := make(Shape, len(sh))
+	for i, p := range sh {
+		out[len(sh)-1-i] = p
+	}
+	return out
+}
+
+// metric returns the metric used by the store.
+func (db *Store) metric() Distance {
+	if db.Distance == nil {
+		return BezierDistance{Weights: db.Weights}
+	}
+	return db.Distance
+}
+
+// prefixDistance compares the first strokes of [store] with [input],
+// returning Inf if [store] has less strokes than [input]
+func prefixDistance(metric Distance, store, input Footprint) Fl {
+	nbStrokes := len(input.Strokes)
+	if len(store.Strokes) < nbStrokes { // ignore this entry
+		return Inf
+	}
+	store.Strokes = store.Strokes[:nbStrokes] // only mutate the local [store]
+	return metric.Symbol(store, input)
+}
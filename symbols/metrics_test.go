@@ -0,0 +1,84 @@
+package symbols
+
+import (
+	"testing"
+
+	tu "github.com/benoitkugler/pen2latex/testutils"
+)
+
+func TestResampleN(t *testing.T) {
+	points := []Pos{{0, 0}, {10, 0}, {10, 10}}
+	out := resampleN(points, 5)
+	tu.AssertEqual(t, len(out), 5)
+	tu.Assert(t, almostEqualPos(out[0], points[0]))
+	tu.Assert(t, almostEqualPos(out[2], Pos{10, 0}))
+	tu.Assert(t, almostEqualPos(out[4], points[2]))
+
+	tu.AssertEqual(t, resampleN([]Pos{{1, 1}}, 3), Shape{{1, 1}, {1, 1}, {1, 1}})
+	tu.AssertEqual(t, resampleN(points, 1), Shape{{10, 0}})
+}
+
+func TestDTW(t *testing.T) {
+	u := Shape{{0, 0}, {1, 0}, {2, 0}, {3, 0}}
+	tu.AssertEqual(t, dtw(u, u), Fl(0))
+	// time warping : repeated points have no cost
+	tu.AssertEqual(t, dtw(u, Shape{{0, 0}, {1, 0}, {1, 0}, {2, 0}, {3, 0}}), Fl(0))
+	tu.Assert(t, dtw(u, Shape{{0, 1}, {1, 1}, {2, 1}, {3, 1}}) > 0)
+}
+
+func TestMetrics(t *testing.T) {
+	circle := Symbol{generateCircle(Pos{30, 30}, 20, 40)}.Footprint()
+	cross := Symbol{
+		{{0, 0}, {5, 5}, {10, 10}, {15, 15}, {20, 20}},
+		{{20, 0}, {15, 5}, {10, 10}, {5, 15}, {0, 20}},
+	}.Footprint()
+	// same cross, with strokes swapped and reversed
+	swapped := Footprint{Strokes: []Stroke{cross.Strokes[1].reverse(), cross.Strokes[0]}}
+
+	for _, metric := range []Distance{BezierDistance{}, DTWDistance{}, PointCloudDistance{}} {
+		tu.Assert(t, metric.Symbol(circle, circle) < 1e-3)
+		tu.Assert(t, metric.Stroke(circle.Strokes[0], circle.Strokes[0]) < 1e-3)
+		tu.Assert(t, metric.Symbol(circle, cross) == Inf)
+		tu.Assert(t, metric.Symbol(cross, swapped) < metric.Symbol(cross, Symbol{
+			{{0, 0}, {5, 5}, {10, 10}, {15, 15}, {20, 20}},
+			{{0, 10}, {5, 10}, {10, 10}, {15, 10}, {20, 10}},
+		}.Footprint()))
+
+		// empty strokes (from decoded or hand-built footprints) do not panic
+		empty := Footprint{Strokes: []Stroke{{}}}
+		withEmpty := Footprint{Strokes: []Stroke{circle.Strokes[0], {}}}
+		tu.Assert(t, metric.Symbol(empty, empty) == Inf)
+		tu.Assert(t, metric.Symbol(circle, empty) == Inf)
+		tu.Assert(t, metric.Symbol(empty, circle) == Inf)
+		metric.Symbol(withEmpty, cross)
+		metric.Symbol(cross, withEmpty)
+	}
+	// the point cloud ignores the empty strokes
+	withEmpty := Footprint{Strokes: []Stroke{{}, circle.Strokes[0]}}
+	tu.Assert(t, PointCloudDistance{}.Symbol(withEmpty, Footprint{Strokes: []Stroke{circle.Strokes[0], circle.Strokes[0]}}) < Inf)
+}
+
+func TestStoreDistance(t *testing.T) {
+	var empty Store
+	tu.AssertEqual(t, empty.Accuracy(), Fl(-1))
+
+	db := testStoreAllSamples()
+	for _, test := range []struct {
+		metric      Distance
+		minAccuracy Fl
+	}{
+		{nil, 1},
+		{BezierDistance{}, 1},
+		{DTWDistance{}, 0.9},
+		{PointCloudDistance{}, 0.8},
+	} {
+		db.Distance = test.metric
+		tu.Assert(t, db.Accuracy() >= test.minAccuracy)
+
+		// the samples of the store are recognized
+		for _, entry := range db.Symbols {
+			r, _, _ := db.Lookup(entry.Footprint, HeightGrid{})
+			tu.AssertEqual(t, r, entry.R)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package symbols
+
+// This file implements the normalization applied before
+// comparing an input with the entries of a store, see
+// [Store.Deslant] and [Store.Rotation].
+
+// RotationBounds gives the maximum rotation (in degrees) of an input
+// tolerated when matching a rune.
+// The bound should be small for runes which are only distinguished by
+// their orientation (like < and >, or + and ×).
+type RotationBounds struct {
+	Default Fl          // used for the runes not in [PerRune]
+	PerRune map[rune]Fl // overrides [Default]
+}
+
+// DefaultRotationBounds tolerates small rotations,
+// except for the runes distinguished by their orientation.
+var DefaultRotationBounds = RotationBounds{
+	Default: 15,
+	PerRune: map[rune]Fl{
+		'<': 0, '>': 0, '∧': 0, '∨': 0, '^': 0,
+		'+': 0, '×': 0, 'x': 0,
+		'-': 0, '|': 0, '/': 0, '\\': 0,
+		'⊂': 0, '⊃': 0, '∩': 0, '∪': 0,
+	},
+}
+
+// Bound returns the bound for [r].
+func (rb RotationBounds) Bound(r rune) Fl {
+	if bound, ok := rb.PerRune[r]; ok {
+		return bound
+	}
+	return rb.Default
+}
+
+// matchInput stores the normalized versions of an input
+type matchInput struct {
+	fp      Footprint          // deslanted if needed
+	rotated map[Fl][]Footprint // bound -> rotated versions of [fp]
+}
+
+// variants returns [fp] and its rotated versions
+func (mi matchInput) variants() []Footprint {
+	out := []Footprint{mi.fp}
+	for _, rotated := range mi.rotated {
+		out = append(out, rotated...)
+	}
+	return out
+}
+
+// prepareInput deslants [input] using the slant of the user layer,
+// and computes the rotations required by [Store.Rotation].
+// The returned value is not modified by the distances computation,
+// and may be used concurrently.
+func (db *Store) prepareInput(idx *storeIndex, input Footprint) matchInput {
+	out := matchInput{fp: input, rotated: map[Fl][]Footprint{}}
+	if db.Deslant {
+		out.fp = input.Transform(Shear(idx.deslanted().slants[UserLayer]))
+	}
+	out.fp = out.fp.withPrimitives()
+
+	bounds := []Fl{db.Rotation.Default}
+	for _, bound := range db.Rotation.PerRune {
+		bounds = append(bounds, bound)
+	}
+	box := out.fp.controlBox()
+	center := box.UL.Add(box.LR).ScaleTo(0.5)
+	for _, bound := range bounds {
+		if _, has := out.rotated[bound]; has || bound <= 0 {
+			continue
+		}
+		var rotated []Footprint
+		for _, angle := range [...]Fl{-bound, -bound / 2, bound / 2, bound} {
+			rotated = append(rotated, out.fp.Transform(Rotation(angle, center)).withPrimitives())
+		}
+		out.rotated[bound] = rotated
+	}
+	return out
+}
+
+// entry returns the footprint of the entry [i], deslanted if needed,
+// with its primitives cached
+func (db *Store) entry(idx *storeIndex, i int) Footprint { return idx.entry(i, db.Deslant) }
+
+// entryDistance returns the distance between the entry [i] and [input],
+// using the metric of the store, restricted to the first strokes of the entry if [compatible] is true.
+// The smallest distance among the tolerated rotations is returned.
+func (db *Store) entryDistance(idx *storeIndex, i int, input matchInput, compatible bool) Fl {
+	metric := db.metric()
+	distance := metric.Symbol
+	if compatible {
+		distance = func(entry, input Footprint) Fl { return prefixDistance(metric, entry, input) }
+	}
+
+	entry := db.entry(idx, i)
+	d := distance(entry, input.fp)
+	for _, rotated := range input.rotated[db.Rotation.Bound(db.Symbols[i].R)] {
+		d = Min(d, distance(entry, rotated))
+	}
+	return d
+}
+
+// layerSlants estimates the slant of each layer,
+// using all its entries, where [layers] is aligned with [fps]
+func layerSlants(fps []Footprint, layers []Layer) (out [BaseLayer + 1]Fl) {
+	for _, layer := range [...]Layer{UserLayer, TeamLayer, BaseLayer} {
+		var inLayer []Footprint
+		for i, fp := range fps {
+			if layers[i] == layer {
+				inLayer = append(inLayer, fp)
+			}
+		}
+		out[layer] = EstimateSlant(inLayer...)
+	}
+	return out
+}
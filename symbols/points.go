@@ -185,6 +185,34 @@ func (sy Symbol) Union() Shape {
 	return out
 }
 
+// PointInfo stores the optional information recorded
+// with a point of a [Shape].
+type PointInfo struct {
+	Time     Fl // in milliseconds, relative to the first point of the [Symbol]
+	Pressure Fl // in [0, 1], or 0 if not supported by the device
+}
+
+// ShapeInfo stores the information for each point of a [Shape].
+// It is empty if no information has been recorded.
+type ShapeInfo []PointInfo
+
+// SymbolInfo stores the information for each [Shape] of a [Symbol].
+// It is empty if no information has been recorded.
+type SymbolInfo []ShapeInfo
+
+// matches returns true if [si] is aligned with [sy]
+func (si SymbolInfo) matches(sy Symbol) bool {
+	if len(si) != len(sy) {
+		return false
+	}
+	for i, shape := range sy {
+		if len(si[i]) != len(shape) {
+			return false
+		}
+	}
+	return true
+}
+
 func (seg segment) toPoints() Shape {
 	const nbPoints = 20
 	var out Shape
@@ -0,0 +1,254 @@
+package symbols
+
+import "math"
+
+// This file implements the preprocessing applied to the
+// raw input points, before fitting Bezier curves.
+// Input devices produce very different point densities and noise,
+// so each step is configurable, and steps are combined in a [Pipeline].
+
+// PreprocessStep is one step of a [Pipeline].
+type PreprocessStep interface {
+	// Apply returns the processed points.
+	// It must not modify [points], and should
+	// return at least one point if [points] is not empty.
+	Apply(points []Pos) []Pos
+}
+
+// Pipeline applies a list of steps, in order.
+type Pipeline []PreprocessStep
+
+// DefaultPipeline is the preprocessing used by [Symbol.Footprint].
+var DefaultPipeline = Pipeline{RemoveArtifacts{}}
+
+// Apply applies each step in order.
+func (pi Pipeline) Apply(points []Pos) []Pos {
+	for _, step := range pi {
+		points = step.Apply(points)
+	}
+	return points
+}
+
+// RemoveArtifacts removes the duplicated points,
+// and the non significant moves at the start and the end
+// of a shape, often captured with tablets.
+type RemoveArtifacts struct{}
+
+func (RemoveArtifacts) Apply(points []Pos) []Pos { return removeSideArtifacts(points) }
+
+// FilterJitter removes the points closer than [MinDistance]
+// to the previous (kept) point.
+// The first and the last points are always kept.
+type FilterJitter struct {
+	MinDistance Fl
+}
+
+func (fj FilterJitter) Apply(points []Pos) []Pos {
+	if len(points) <= 2 {
+		return append([]Pos(nil), points...)
+	}
+	out := []Pos{points[0]}
+	for _, p := range points[1 : len(points)-1] {
+		if distP(p, out[len(out)-1]) >= fj.MinDistance {
+			out = append(out, p)
+		}
+	}
+	last := points[len(points)-1]
+	if len(out) >= 2 && distP(last, out[len(out)-1]) < fj.MinDistance {
+		out[len(out)-1] = last // keep the exact end
+	} else {
+		out = append(out, last)
+	}
+	return out
+}
+
+// Resample distributes points uniformly along the path,
+// separated by [Spacing] (in arc length).
+// The first and the last points are always kept.
+type Resample struct {
+	Spacing Fl
+}
+
+func (rs Resample) Apply(points []Pos) []Pos {
+	if len(points) <= 1 || rs.Spacing <= 0 {
+		return append([]Pos(nil), points...)
+	}
+	out := []Pos{points[0]}
+	var (
+		previous = points[0]
+		covered  Fl // length since the last point added
+	)
+	for i := 1; i < len(points); {
+		next := points[i]
+		d := distP(previous, next)
+		if covered+d >= rs.Spacing && d > 0 {
+			t := (rs.Spacing - covered) / d
+			previous = previous.Add(next.Sub(previous).ScaleTo(t))
+			out = append(out, previous)
+			covered = 0
+		} else {
+			covered += d
+			previous = next
+			i++
+		}
+	}
+	last := points[len(points)-1]
+	if distP(last, out[len(out)-1]) < rs.Spacing/2 && len(out) >= 2 {
+		out[len(out)-1] = last
+	} else if out[len(out)-1] != last {
+		out = append(out, last)
+	}
+	return out
+}
+
+// GaussianSmoothing convolves the points with a Gaussian kernel
+// of deviation [Sigma] (expressed in number of points).
+// It is best used after [Resample].
+// The first and the last points are kept unchanged.
+type GaussianSmoothing struct {
+	Sigma Fl
+}
+
+func (gs GaussianSmoothing) Apply(points []Pos) []Pos {
+	out := append([]Pos(nil), points...)
+	if gs.Sigma <= 0 || len(points) <= 2 {
+		return out
+	}
+	radius := int(math.Ceil(float64(3 * gs.Sigma)))
+	weights := make([]Fl, radius+1)
+	for k := range weights {
+		weights[k] = Fl(math.Exp(-float64(k*k) / float64(2*gs.Sigma*gs.Sigma)))
+	}
+	for i := 1; i < len(points)-1; i++ {
+		var (
+			sum   Pos
+			total Fl
+		)
+		for j := i - radius; j <= i+radius; j++ {
+			if j < 0 || j >= len(points) {
+				continue // truncate the kernel at the borders
+			}
+			k := j - i
+			if k < 0 {
+				k = -k
+			}
+			sum = sum.Add(points[j].ScaleTo(weights[k]))
+			total += weights[k]
+		}
+		out[i] = sum.ScaleTo(1 / total)
+	}
+	return out
+}
+
+// Simplify implements the Douglas-Peucker algorithm :
+// points closer than [Tolerance] to the simplified path are removed.
+type Simplify struct {
+	Tolerance Fl
+}
+
+func (sp Simplify) Apply(points []Pos) []Pos {
+	if len(points) <= 2 {
+		return append([]Pos(nil), points...)
+	}
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+	sp.simplify(points, keep, 0, len(points)-1)
+	var out []Pos
+	for i, p := range points {
+		if keep[i] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// simplify marks the points to keep between [start] and [end] (included)
+func (sp Simplify) simplify(points []Pos, keep []bool, start, end int) {
+	if end-start <= 1 {
+		return
+	}
+	index, maxDistance := -1, Fl(-1)
+	for i := start + 1; i < end; i++ {
+		if d := distanceToSegment(points[i], points[start], points[end]); d > maxDistance {
+			index, maxDistance = i, d
+		}
+	}
+	if maxDistance <= sp.Tolerance {
+		return
+	}
+	keep[index] = true
+	sp.simplify(points, keep, start, index)
+	sp.simplify(points, keep, index, end)
+}
+
+// distanceToSegment returns the distance from [p] to the segment [a, b]
+func distanceToSegment(p, a, b Pos) Fl {
+	ab := b.Sub(a)
+	l2 := ab.NormSquared()
+	if l2 == 0 {
+		return distP(p, a)
+	}
+	t := dotProduct(p.Sub(a), ab) / l2
+	t = Max(0, Min(1, t))
+	return distP(p, a.Add(ab.ScaleTo(t)))
+}
+
+// RemoveHooks removes the hooks (short parts with a sharp change of direction)
+// often found at the start and the end of a pen stroke.
+// A hook is detected if the path turns by more than [MinAngle] (in degrees)
+// within [MaxLength] (in arc length) of an extremity.
+// Strokes shorter than 3 * [MaxLength] are left unchanged.
+type RemoveHooks struct {
+	MaxLength Fl
+	MinAngle  Fl
+}
+
+func (rh RemoveHooks) Apply(points []Pos) []Pos {
+	if len(points) < 3 || rh.MaxLength <= 0 {
+		return append([]Pos(nil), points...)
+	}
+	lengths := pathLengths(points)
+	if lengths[len(lengths)-1] < 3*rh.MaxLength {
+		return append([]Pos(nil), points...)
+	}
+
+	start := rh.hookEnd(points, lengths)
+
+	reversed := make([]Pos, len(points))
+	for i, p := range points {
+		reversed[len(points)-1-i] = p
+	}
+	end := len(points) - rh.hookEnd(reversed, pathLengths(reversed))
+
+	return append([]Pos(nil), points[start:end]...)
+}
+
+// hookEnd returns the index where the hook at the start of [points] ends,
+// or 0 if there is no hook
+func (rh RemoveHooks) hookEnd(points []Pos, lengths []Fl) int {
+	cut, maxAngle := 0, rh.MinAngle
+	for k := 1; k < len(points)-1 && lengths[k] <= rh.MaxLength; k++ {
+		// compare the direction of the hook with the direction after it
+		after := k + 1
+		for after < len(points)-1 && lengths[after]-lengths[k] < rh.MaxLength {
+			after++
+		}
+		incoming, outgoing := points[k].Sub(points[0]), points[after].Sub(points[k])
+		if incoming.NormSquared() == 0 || outgoing.NormSquared() == 0 {
+			continue
+		}
+		if a := abs(angle(incoming, outgoing)); a > maxAngle {
+			cut, maxAngle = k, a
+		}
+	}
+	return cut
+}
+
+// pathLengths returns the cumulated length at each point
+func pathLengths(points []Pos) []Fl {
+	out := make([]Fl, len(points))
+	for i := 1; i < len(points); i++ {
+		out[i] = out[i-1] + distP(points[i-1], points[i])
+	}
+	return out
+}
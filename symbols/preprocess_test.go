@@ -0,0 +1,129 @@
+package symbols
+
+import (
+	"reflect"
+	"testing"
+
+	tu "github.com/benoitkugler/pen2latex/testutils"
+)
+
+func horizontalLine(length Fl, nbPoints int) []Pos {
+	out := make([]Pos, nbPoints)
+	for i := range out {
+		out[i] = Pos{length * Fl(i) / Fl(nbPoints-1), 0}
+	}
+	return out
+}
+
+func TestPreprocessStepsDontModifyInput(t *testing.T) {
+	steps := []PreprocessStep{
+		RemoveArtifacts{},
+		FilterJitter{MinDistance: 1},
+		Resample{Spacing: 2},
+		GaussianSmoothing{Sigma: 1},
+		Simplify{Tolerance: 0.5},
+		RemoveHooks{MaxLength: 5, MinAngle: 90},
+	}
+	for _, step := range steps {
+		points := Shape{{0, 0}, {0, 3}, {1, 0}, {2, 0.5}, {3, 0}, {3, 0}, {10, 1}, {20, 0}, {30, 0}, {30, 1}}
+		input := append(Shape(nil), points...)
+		out := step.Apply(points)
+		tu.Assert(t, reflect.DeepEqual(points, input))
+		tu.Assert(t, len(out) > 0)
+
+		tu.AssertEqual(t, len(step.Apply(nil)), 0)
+		tu.AssertEqual(t, len(step.Apply([]Pos{{1, 1}})), 1)
+	}
+}
+
+func TestFilterJitter(t *testing.T) {
+	points := []Pos{{0, 0}, {0.1, 0}, {0.2, 0.1}, {1, 0}, {1.1, 0.1}, {2, 0}, {2.05, 0}}
+	out := FilterJitter{MinDistance: 0.5}.Apply(points)
+	tu.AssertEqual(t, out, []Pos{{0, 0}, {1, 0}, {2.05, 0}})
+}
+
+func TestResample(t *testing.T) {
+	points := []Pos{{0, 0}, {0.5, 0}, {3, 0}, {3.2, 0}, {7, 0}, {10, 0}}
+	out := Resample{Spacing: 1}.Apply(points)
+	tu.AssertEqual(t, len(out), 11)
+	for i, p := range out {
+		tu.Assert(t, almostEqualPos(p, Pos{Fl(i), 0}))
+	}
+
+	// around a corner
+	out = Resample{Spacing: 1}.Apply([]Pos{{0, 0}, {5, 0}, {5, 5}})
+	tu.AssertEqual(t, len(out), 11)
+	tu.Assert(t, almostEqualPos(out[len(out)-1], Pos{5, 5}))
+}
+
+func TestGaussianSmoothing(t *testing.T) {
+	// zigzag around the horizontal axis
+	points := horizontalLine(20, 21)
+	for i := range points {
+		if i%2 == 1 {
+			points[i].Y = 1
+		}
+	}
+	out := GaussianSmoothing{Sigma: 1.5}.Apply(points)
+	tu.AssertEqual(t, out[0], points[0])
+	tu.AssertEqual(t, out[20], points[20])
+	for _, p := range out[3 : len(out)-3] {
+		tu.Assert(t, abs(p.Y-0.5) < 0.1)
+	}
+}
+
+func TestSimplify(t *testing.T) {
+	out := Simplify{Tolerance: 0.1}.Apply(horizontalLine(10, 20))
+	tu.AssertEqual(t, out, []Pos{{0, 0}, {10, 0}})
+
+	// L shape
+	points := append(horizontalLine(10, 11), Pos{10, 1}, Pos{10, 2}, Pos{10, 3})
+	out = Simplify{Tolerance: 0.1}.Apply(points)
+	tu.AssertEqual(t, out, []Pos{{0, 0}, {10, 0}, {10, 3}})
+}
+
+func TestRemoveHooks(t *testing.T) {
+	line := horizontalLine(50, 51)
+	// hooks going down at the start and up at the end
+	points := append([]Pos{{0, 3}, {0, 2}, {0, 1}}, line...)
+	points = append(points, Pos{50, -1}, Pos{50, -2})
+
+	out := RemoveHooks{MaxLength: 5, MinAngle: 60}.Apply(points)
+	tu.AssertEqual(t, out, line)
+
+	// no hooks
+	out = RemoveHooks{MaxLength: 5, MinAngle: 60}.Apply(line)
+	tu.AssertEqual(t, out, line)
+
+	// too short strokes are kept
+	short := []Pos{{0, 3}, {0, 2}, {0, 1}, {0, 0}, {1, 0}, {2, 0}}
+	out = RemoveHooks{MaxLength: 5, MinAngle: 60}.Apply(short)
+	tu.AssertEqual(t, out, short)
+}
+
+func TestPipeline(t *testing.T) {
+	// a noisy, dense, line with a hook
+	var points Shape
+	for i := 0; i < 400; i++ {
+		y := Fl(0)
+		if i%3 == 0 {
+			y = 0.3
+		}
+		points = append(points, Pos{Fl(i) / 4, y})
+	}
+	points = append(Shape{{0, 4}, {0, 2}}, points...)
+
+	pipeline := Pipeline{
+		FilterJitter{MinDistance: 0.5},
+		RemoveHooks{MaxLength: 6, MinAngle: 60},
+		Resample{Spacing: 2},
+		GaussianSmoothing{Sigma: 1},
+		Simplify{Tolerance: 0.5},
+	}
+	processed := pipeline.Apply(points)
+	tu.Assert(t, len(processed) < 10)
+
+	fp := Symbol{points}.FootprintWith(pipeline)
+	tu.AssertEqual(t, len(fp.Strokes), 1)
+	tu.Assert(t, fp.Strokes[0].IsLine())
+}
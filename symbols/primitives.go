@@ -0,0 +1,364 @@
+package symbols
+
+import "math"
+
+// This file implements the description of a [Stroke] by
+// simple geometric primitives (segments, arcs and loops), which are more
+// reliable than the raw Bezier curves to distinguish shapes like o, 0 and O.
+
+// PrimitiveKind is the type of a [Primitive].
+type PrimitiveKind uint8
+
+const (
+	FreeCurve PrimitiveKind = iota // a Bezier curve without simpler description
+	Segment                        // a (roughly) straight line
+	Arc                            // a circular or elliptic arc
+	Loop                           // a closed circle or ellipse
+)
+
+func (pk PrimitiveKind) String() string {
+	switch pk {
+	case FreeCurve:
+		return "FreeCurve"
+	case Segment:
+		return "Segment"
+	case Arc:
+		return "Arc"
+	case Loop:
+		return "Loop"
+	default:
+		panic("exhaustive switch")
+	}
+}
+
+// Ellipse is an ellipse (or a circle) fitted to a part of a stroke.
+type Ellipse struct {
+	Center Pos
+	Radii  Pos // the semi-axes, with Radii.X >= Radii.Y
+	Angle  Fl  // the orientation of the major axis, in degrees, in [0, 180[
+}
+
+// Aspect returns the ratio between the minor and the major axis,
+// 1 for a circle.
+func (el Ellipse) Aspect() Fl {
+	if el.Radii.X == 0 {
+		return 0
+	}
+	return el.Radii.Y / el.Radii.X
+}
+
+// Primitive describes a part of a [Stroke].
+type Primitive struct {
+	Kind PrimitiveKind
+
+	// Start and End are the indices of the curves
+	// [Start, End) of the stroke covered by the primitive.
+	Start, End int
+
+	// The following fields are only set for [Arc] and [Loop].
+
+	Ellipse Ellipse
+	// Sweep is the signed angle covered, in degrees : it is positive
+	// for a clockwise rotation on screen (the Y axis being downward).
+	Sweep Fl
+	// Closure is the distance between the first and the last point,
+	// relative to the major axis of the ellipse (0 for a closed loop).
+	Closure Fl
+	// Error is the root mean square distance between the points and
+	// the ellipse, relative to the major semi-axis.
+	Error Fl
+}
+
+// IsClockwise returns true if the arc is drawn clockwise on screen.
+func (pr Primitive) IsClockwise() bool { return pr.Sweep > 0 }
+
+// thresholds used to classify the primitives
+const (
+	maxEllipseError  = 0.06 // relative to the major semi-axis
+	minEllipseAspect = 0.15 // flatter ellipses are rather lines
+	minArcSweep      = 100  // in degrees
+	minLoopSweep     = 300  // in degrees
+	maxLoopClosure   = 0.5  // relative to the major semi-axis
+	maxArcTangent    = 45   // in degrees, between consecutive curves of an arc
+
+	maxLoopAspectDiff = 0.25 // used when matching loops
+)
+
+// Primitives segments the stroke into primitives, covering
+// all its curves, in order : consecutive curves which are well approximated
+// by an ellipse (in the least squares sense) are merged into an [Arc] or a [Loop],
+// linear curves are [Segment]s, and the other ones are [FreeCurve]s.
+func (fp Stroke) Primitives() []Primitive {
+	if fp.primitives != nil {
+		return append([]Primitive(nil), fp.primitives...)
+	}
+	return fp.computePrimitives()
+}
+
+func (fp Stroke) computePrimitives() []Primitive {
+	var out []Primitive
+	for i := 0; i < len(fp.Curves); {
+		if fp.Curves[i].IsRoughlyLinear() {
+			out = append(out, Primitive{Kind: Segment, Start: i, End: i + 1})
+			i++
+			continue
+		}
+
+		// extend the arc as much as possible
+		best, ok := fitEllipticArc(fp.Curves[i : i+1])
+		end := i + 1
+		for ok && end < len(fp.Curves) {
+			next := fp.Curves[end]
+			if next.IsRoughlyLinear() || tangentAngle(fp.Curves[end-1], next) > maxArcTangent {
+				break
+			}
+			candidate, okNext := fitEllipticArc(fp.Curves[i : end+1])
+			if !okNext {
+				break
+			}
+			best, end = candidate, end+1
+		}
+
+		sweep := abs(best.Sweep)
+		switch {
+		case !ok || sweep < minArcSweep:
+			for j := i; j < end; j++ {
+				out = append(out, Primitive{Kind: FreeCurve, Start: j, End: j + 1})
+			}
+		case sweep >= minLoopSweep && best.Closure <= maxLoopClosure:
+			best.Kind, best.Start, best.End = Loop, i, end
+			out = append(out, best)
+		default:
+			best.Kind, best.Start, best.End = Arc, i, end
+			out = append(out, best)
+		}
+		i = end
+	}
+	return out
+}
+
+// Loop returns the first [Loop] primitive of the stroke, if any.
+func (fp Stroke) Loop() (Primitive, bool) {
+	primitives := fp.primitives
+	if primitives == nil {
+		primitives = fp.computePrimitives()
+	}
+	for _, pr := range primitives {
+		if pr.Kind == Loop {
+			return pr, true
+		}
+	}
+	return Primitive{}, false
+}
+
+// Primitives returns the primitives of each stroke.
+func (sf Footprint) Primitives() [][]Primitive {
+	out := make([][]Primitive, len(sf.Strokes))
+	for i, st := range sf.Strokes {
+		out[i] = st.Primitives()
+	}
+	return out
+}
+
+// withPrimitives returns a copy of [sf] caching the primitives of its strokes,
+// used for the footprints compared many times (the entries of the store and the inputs).
+// The cache is kept by the scaling and the reversal used during matching,
+// but not by the other transformations.
+func (sf Footprint) withPrimitives() Footprint {
+	out := Footprint{Strokes: make([]Stroke, len(sf.Strokes))}
+	for i, st := range sf.Strokes {
+		st.primitives = st.computePrimitives()
+		out.Strokes[i] = st
+	}
+	return out
+}
+
+// scale applies [tr] to the ellipse of the primitive
+func (pr Primitive) scale(tr Trans) Primitive {
+	pr.Ellipse.Center = tr.Apply(pr.Ellipse.Center)
+	pr.Ellipse.Radii = pr.Ellipse.Radii.ScaleTo(tr.Scale)
+	return pr
+}
+
+// reverse returns the primitive covering the same curves
+// in a stroke with [nbCurves] curves, drawn in the opposite direction
+func (pr Primitive) reverse(nbCurves int) Primitive {
+	pr.Start, pr.End = nbCurves-pr.End, nbCurves-pr.Start
+	pr.Sweep = -pr.Sweep
+	return pr
+}
+
+// fitEllipticArc fits an ellipse to the points of [curves], returning false
+// if the points are not well approximated by an ellipse.
+// The kind and the range of the returned primitive are not set.
+func fitEllipticArc(curves []Bezier) (Primitive, bool) {
+	var points Shape
+	for _, cu := range curves {
+		points = append(points, cu.toPoints()...)
+	}
+	points = append(points, curves[len(curves)-1].P3)
+
+	el, ok := fitEllipse(points)
+	if !ok {
+		return Primitive{}, false
+	}
+
+	// compare the size of the ellipse with the extent of the points,
+	// to reject almost straight arcs
+	bbox := points.BoundingBox()
+	if el.Radii.X > 2*Max(bbox.Width(), bbox.Height()) {
+		return Primitive{}, false
+	}
+
+	out := Primitive{Ellipse: el}
+	theta := float64(el.Angle) * math.Pi / 180
+	cos, sin := Fl(math.Cos(theta)), Fl(math.Sin(-theta))
+	var (
+		sumError  Fl
+		lastAngle Fl
+	)
+	for i, p := range points {
+		// coordinates in the frame of the ellipse, mapped to the unit circle
+		q := p.Sub(el.Center)
+		q.rotate(cos, sin)
+		u, v := q.X/el.Radii.X, q.Y/el.Radii.Y
+		r := Sqrt(u*u + v*v)
+		e := (r - 1) * q.Norm() / Max(r, 1e-6) // distance along the radius
+		sumError += e * e
+
+		a := Fl(math.Atan2(float64(v), float64(u)) * 180 / math.Pi)
+		if i != 0 {
+			delta := a - lastAngle
+			if delta > 180 {
+				delta -= 360
+			} else if delta < -180 {
+				delta += 360
+			}
+			out.Sweep += delta
+		}
+		lastAngle = a
+	}
+	out.Error = Sqrt(sumError/Fl(len(points))) / el.Radii.X
+	out.Closure = distP(points[0], points[len(points)-1]) / el.Radii.X
+	if out.Error > maxEllipseError {
+		return Primitive{}, false
+	}
+	return out, true
+}
+
+// fitEllipse fits the conic x² + Bxy + Cy² + Dx + Ey + F = 0 to [points],
+// using linear least squares, and returns false if it is not
+// a (non degenerate) ellipse.
+func fitEllipse(points Shape) (Ellipse, bool) {
+	if len(points) < 5 {
+		return Ellipse{}, false
+	}
+
+	// normalize the points for numerical stability
+	var mean Pos
+	for _, p := range points {
+		mean = mean.Add(p)
+	}
+	mean.Scale(1 / Fl(len(points)))
+	var scale float64
+	for _, p := range points {
+		scale += float64(p.Sub(mean).NormSquared())
+	}
+	scale = math.Sqrt(scale / float64(len(points)))
+	if scale == 0 {
+		return Ellipse{}, false
+	}
+
+	// normal equations for the unknowns B, C, D, E, F
+	var (
+		ata [5][5]float64
+		atb [5]float64
+	)
+	for _, p := range points {
+		x, y := float64(p.X-mean.X)/scale, float64(p.Y-mean.Y)/scale
+		row := [5]float64{x * y, y * y, x, y, 1}
+		for i := range row {
+			for j := range row {
+				ata[i][j] += row[i] * row[j]
+			}
+			atb[i] -= row[i] * x * x
+		}
+	}
+	sol, ok := solveLinear5(ata, atb)
+	if !ok {
+		return Ellipse{}, false
+	}
+	B, C, D, E, F := sol[0], sol[1], sol[2], sol[3], sol[4]
+
+	det := 4*C - B*B
+	if det <= 0 { // not an ellipse
+		return Ellipse{}, false
+	}
+	// the center cancels the gradient
+	cx := (B*E - 2*C*D) / det
+	cy := (B*D - 2*E) / det
+	fc := F + (D*cx+E*cy)/2 // value of the conic at the center
+	if fc >= 0 {            // imaginary ellipse
+		return Ellipse{}, false
+	}
+
+	// axes of the quadratic form [[1, B/2], [B/2, C]]
+	theta := math.Atan2(B, 1-C) / 2
+	quadratic := func(t float64) float64 {
+		c, s := math.Cos(t), math.Sin(t)
+		return c*c + B*c*s + C*s*s
+	}
+	r1 := math.Sqrt(-fc / quadratic(theta))
+	r2 := math.Sqrt(-fc / quadratic(theta+math.Pi/2))
+	if r1 < r2 {
+		r1, r2 = r2, r1
+		theta += math.Pi / 2
+	}
+	if r2/r1 < minEllipseAspect {
+		return Ellipse{}, false
+	}
+	angle := math.Mod(theta*180/math.Pi, 180)
+	if angle < 0 {
+		angle += 180
+	}
+
+	return Ellipse{
+		Center: Pos{X: Fl(cx*scale) + mean.X, Y: Fl(cy*scale) + mean.Y},
+		Radii:  Pos{X: Fl(r1 * scale), Y: Fl(r2 * scale)},
+		Angle:  Fl(angle),
+	}, true
+}
+
+// solveLinear5 solves the linear system [a]x = [b], using
+// a Gaussian elimination with partial pivoting.
+func solveLinear5(a [5][5]float64, b [5]float64) (x [5]float64, ok bool) {
+	const n = 5
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return x, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		b[col], b[pivot] = b[pivot], b[col]
+		for row := col + 1; row < n; row++ {
+			f := a[row][col] / a[col][col]
+			for k := col; k < n; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+			b[row] -= f * b[col]
+		}
+	}
+	for row := n - 1; row >= 0; row-- {
+		s := b[row]
+		for k := row + 1; k < n; k++ {
+			s -= a[row][k] * x[k]
+		}
+		x[row] = s / a[row][row]
+	}
+	return x, true
+}
@@ -0,0 +1,110 @@
+package symbols
+
+import (
+	"testing"
+
+	tu "github.com/benoitkugler/pen2latex/testutils"
+)
+
+func TestFitEllipse(t *testing.T) {
+	el, ok := fitEllipse(generateEllipse(Pos{30, 40}, 20, 10, 50, 0.5))
+	tu.Assert(t, ok)
+	tu.Assert(t, almostEqualPos(el.Center, Pos{30, 40}))
+	tu.Assert(t, abs(el.Radii.X-20) < 0.1 && abs(el.Radii.Y-10) < 0.1)
+	tu.Assert(t, el.Angle < 1 || el.Angle > 179)
+	tu.Assert(t, abs(el.Aspect()-0.5) < 0.01)
+
+	_, ok = fitEllipse(Shape{{0, 0}, {1, 1}, {2, 2}, {3, 3}, {4, 4}, {5, 5}})
+	tu.Assert(t, !ok)
+}
+
+func TestPrimitives(t *testing.T) {
+	kinds := func(st Stroke) (out []PrimitiveKind) {
+		for _, pr := range st.Primitives() {
+			out = append(out, pr.Kind)
+		}
+		return out
+	}
+
+	circle := newFp(generateEllipse(Pos{30, 30}, 20, 20, 60, 1))
+	tu.AssertEqual(t, kinds(circle), []PrimitiveKind{Loop})
+	loop, ok := circle.Loop()
+	tu.Assert(t, ok)
+	tu.Assert(t, loop.Ellipse.Aspect() > 0.9)
+	tu.Assert(t, loop.IsClockwise()) // the Y axis is downward
+	tu.Assert(t, !circle.reverse().Primitives()[0].IsClockwise())
+
+	zero := newFp(generateEllipse(Pos{30, 30}, 10, 20, 60, 1))
+	loop, ok = zero.Loop()
+	tu.Assert(t, ok)
+	tu.Assert(t, abs(loop.Ellipse.Aspect()-0.5) < 0.1)
+	tu.Assert(t, abs(loop.Ellipse.Angle-90) < 5)
+
+	c := newFp(generateEllipse(Pos{30, 30}, 20, 20, 60, 0.7))
+	tu.AssertEqual(t, kinds(c), []PrimitiveKind{Arc})
+	tu.Assert(t, abs(abs(c.Primitives()[0].Sweep)-252) < 20)
+
+	line := newFp(Shape{{0, 0}, {10, 10}, {20, 20}})
+	tu.AssertEqual(t, kinds(line), []PrimitiveKind{Segment})
+
+	// the primitives cover every curve of the stroke
+	for _, sh := range []Shape{shapes[18].shapes[0], shapes[17].shapes[1], shapes[5].shapes[2], shapes[22].shapes[1]} {
+		st := newFp(sh)
+		prs := st.Primitives()
+		tu.AssertEqual(t, prs[0].Start, 0)
+		tu.AssertEqual(t, prs[len(prs)-1].End, len(st.Curves))
+		for i := 1; i < len(prs); i++ {
+			tu.AssertEqual(t, prs[i].Start, prs[i-1].End)
+		}
+	}
+
+	g := newFp(shapes[18].shapes[0])
+	_, ok = g.Loop()
+	tu.Assert(t, ok)
+	y := newFp(shapes[17].shapes[1])
+	_, ok = y.Loop()
+	tu.Assert(t, !ok)
+}
+
+func TestCachedPrimitives(t *testing.T) {
+	fp := Symbol{generateEllipse(Pos{30, 30}, 10, 20, 60, 1), generateEllipse(Pos{60, 30}, 20, 20, 60, 0.6)}.Footprint()
+	cached := fp.withPrimitives()
+	for i, st := range cached.Strokes {
+		tu.AssertEqual(t, st.Primitives(), fp.Strokes[i].Primitives())
+
+		// the cache follows the scaling
+		tr := Trans{Scale: 2, Translation: Pos{5, -3}}
+		got, exp := st.scale(tr).Primitives(), fp.Strokes[i].scale(tr).Primitives()
+		tu.AssertEqual(t, len(got), len(exp))
+		for j := range got {
+			tu.Assert(t, almostEqualPos(got[j].Ellipse.Center, exp[j].Ellipse.Center))
+			tu.Assert(t, abs(got[j].Ellipse.Radii.X-exp[j].Ellipse.Radii.X) < 0.01)
+		}
+
+		// and the reversal
+		got, exp = st.reverse().Primitives(), fp.Strokes[i].reverse().Primitives()
+		tu.AssertEqual(t, len(got), len(exp))
+		for j := range got {
+			tu.AssertEqual(t, got[j].Kind, exp[j].Kind)
+			tu.AssertEqual(t, got[j].IsClockwise(), exp[j].IsClockwise())
+			tu.AssertEqual(t, [2]int{got[j].Start, got[j].End}, [2]int{exp[j].Start, exp[j].End})
+		}
+	}
+
+	// the cache is not visible in the serialized store
+	var db Store
+	db.Add('o', Symbol{generateEllipse(Pos{30, 30}, 10, 20, 60, 1)})
+	db.Symbols[0].Footprint = db.Symbols[0].Footprint.withPrimitives()
+	db2, err := parseStore(encodeStore(db))
+	tu.AssertNoErr(t, err)
+	tu.AssertEqual(t, db2.Symbols[0].Footprint.Strokes[0].Curves, db.Symbols[0].Footprint.Strokes[0].Curves)
+}
+
+func TestMatchLoops(t *testing.T) {
+	o := Symbol{generateEllipse(Pos{30, 30}, 20, 20, 60, 1)}.Footprint()
+	o2 := Symbol{generateEllipse(Pos{30, 30}, 20, 18, 60, 1)}.Footprint()
+	zero := Symbol{generateEllipse(Pos{30, 30}, 10, 20, 60, 1)}.Footprint()
+
+	tu.Assert(t, distanceSymbolsExact(o, o2) < distanceSymbolsExact(o, zero))
+	tu.Assert(t, distanceSymbolsExact(o2, o) < distanceSymbolsExact(zero, o))
+}
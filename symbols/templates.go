@@ -0,0 +1,491 @@
+package symbols
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// This file implements structural templates, used for the symbols with variable
+// size (like √, big brackets or long arrows), which can't be matched against fixed samples.
+// A template is described by a rule, evaluated against the fitted curves of each stroke :
+//
+//	rule       := [ constraint { constraint } ":" ] stroke { ";" stroke }
+//	stroke     := item { item }
+//	item       := atom [ "?" | "+" | "*" ]
+//	atom       := element | "(" stroke { "|" stroke } ")"
+//	element    := name { "." qualifier }
+//
+// Each element matches exactly one curve :
+//   - line : a roughly linear curve, with the qualifiers h and v (horizontal and
+//     vertical directions, with a tolerance of 10° and 30°), left, right, up and down
+//     (the sign of the displacement), and sharp (the line turns back from the previous
+//     line, with an angle between 0° and 45°, like the second half of a V). A sharp line
+//     must directly follow a line element of the same sequence.
+//   - curve : a curve which is not linear, with the qualifiers left, right, up and down,
+//     giving the side of its bulge
+//   - hook : a short curve (relatively to the stroke) which is not linear, with the same qualifiers as curve
+//   - cup : a U shape, opening upward
+//   - any : any curve
+//
+// The constraints apply to the bounding box of the footprint :
+//   - tall : the height is at least 3 times the width
+//   - wide : the width is at least 3 times the height
+//   - big : the size is at least the upper height of the [HeightGrid]
+//
+// For instance, "(line.down line.up.sharp | cup) line.h.right" describes a √,
+// and "tall big: line.v; line.v" a ‖.
+
+// Template associates a rune to a structural rule.
+type Template struct {
+	R    rune
+	Rule string
+
+	constraints []string
+	strokes     []templateNode
+}
+
+// Templates is a list of [Template], matched in order.
+type Templates []Template
+
+// DefaultTemplates are the templates used by the layout package, when the samples
+// of the store do not match the input.
+// All of them but √ require a size (big), so that they do not catch
+// the regular symbols drawn at the usual size (like an f with a tail for ∫),
+// and are not matched when the [HeightGrid] is empty.
+//
+// There is no template for overlines : they have the same shape
+// as the fraction bar, and are only distinguished by their position.
+var DefaultTemplates = Templates{
+	sqrtTemplate,
+	MustParseTemplate('∫', "tall big: hook line.v hook"),
+	MustParseTemplate('‖', "tall big: line.v; line.v"),
+	MustParseTemplate('|', "tall big: line.v"),
+	MustParseTemplate('(', "tall big: curve.left+"),
+	MustParseTemplate(')', "tall big: curve.right+"),
+	MustParseTemplate('[', "tall big: line.h.left line.v line.h.right"),
+	MustParseTemplate(']', "tall big: line.h.right line.v line.h.left"),
+	MustParseTemplate('⟶', "wide big: line.h.right; line.right line.left"),
+}
+
+// thresholds used by the templates
+const (
+	templateHorizontalTolerance = 10  // in degrees
+	templateVerticalTolerance   = 30  // in degrees
+	templateAspect              = 3   // for tall and wide
+	templateHookLength          = 0.3 // relative to the length of the stroke
+	templateSharpAngle          = 45  // in degrees
+)
+
+// Match returns the rune of the first template matching [fp] in [grid].
+func (ts Templates) Match(fp Footprint, grid HeightGrid) (rune, bool) {
+	for _, t := range ts {
+		if t.Match(fp, grid) {
+			return t.R, true
+		}
+	}
+	return 0, false
+}
+
+// IsPrefix returns true if [fp] matches the first strokes of
+// a template with more strokes than [fp].
+func (ts Templates) IsPrefix(fp Footprint, grid HeightGrid) bool {
+	for _, t := range ts {
+		if len(t.strokes) > len(fp.Strokes) && t.matchStrokes(fp, grid, len(fp.Strokes)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns true if [fp] is described by the template.
+func (t Template) Match(fp Footprint, grid HeightGrid) bool {
+	if len(fp.Strokes) != len(t.strokes) {
+		return false
+	}
+	return t.matchStrokes(fp, grid, len(fp.Strokes))
+}
+
+// matchStrokes checks the constraints and the [n] first strokes
+func (t Template) matchStrokes(fp Footprint, grid HeightGrid, n int) bool {
+	if n == 0 || len(fp.Strokes) < n {
+		return false
+	}
+	bbox := fp.BoundingBox()
+	for _, c := range t.constraints {
+		if !checkConstraint(c, bbox, grid) {
+			return false
+		}
+	}
+	for i, st := range fp.Strokes[:n] {
+		if !matchStrokeNode(t.strokes[i], st) {
+			return false
+		}
+	}
+	return true
+}
+
+func checkConstraint(constraint string, bbox Rect, grid HeightGrid) bool {
+	w, h := bbox.Width(), bbox.Height()
+	switch constraint {
+	case "tall":
+		return h >= templateAspect*w
+	case "wide":
+		return w >= templateAspect*h
+	default: // big
+		size := grid.upperHeight()
+		return size > 0 && Max(w, h) >= size
+	}
+}
+
+// ParseTemplate parses [rule], see the syntax described above.
+func ParseTemplate(r rune, rule string) (Template, error) {
+	out := Template{R: r, Rule: rule}
+	body := rule
+	if head, tail, has := strings.Cut(rule, ":"); has {
+		body = tail
+		for _, c := range strings.Fields(head) {
+			switch c {
+			case "tall", "wide", "big":
+				out.constraints = append(out.constraints, c)
+			default:
+				return Template{}, fmt.Errorf("invalid template %q: unknown constraint %q", rule, c)
+			}
+		}
+	}
+
+	for _, chunk := range strings.Split(body, ";") {
+		p := templateParser{src: []rune(chunk)}
+		node, err := p.parseSequence()
+		if err == nil && !p.isEnd() {
+			err = fmt.Errorf("unexpected %q", p.src[p.pos])
+		}
+		if err == nil && len(node) == 0 {
+			err = fmt.Errorf("empty stroke")
+		}
+		if err != nil {
+			return Template{}, fmt.Errorf("invalid template %q: %s", rule, err)
+		}
+		out.strokes = append(out.strokes, node)
+	}
+	return out, nil
+}
+
+// MustParseTemplate is the same as [ParseTemplate], but panics on invalid rules.
+func MustParseTemplate(r rune, rule string) Template {
+	t, err := ParseTemplate(r, rule)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// ---------------------------- parsing ----------------------------
+
+type templateParser struct {
+	src []rune
+	pos int
+}
+
+func (p *templateParser) skipSpaces() {
+	for p.pos < len(p.src) && unicode.IsSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *templateParser) isEnd() bool {
+	p.skipSpaces()
+	return p.pos >= len(p.src)
+}
+
+// peek returns the next non space character, or 0
+func (p *templateParser) peek() rune {
+	if p.isEnd() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *templateParser) parseSequence() (sequenceNode, error) {
+	var out sequenceNode
+	for {
+		c := p.peek()
+		if c == 0 || c == '|' || c == ')' {
+			return out, nil
+		}
+		atom, err := p.parseAtom()
+		if err != nil {
+			return nil, err
+		}
+		if el, ok := atom.(elementNode); ok && el.hasQualifier("sharp") {
+			// the angle is measured from the previous curve, which must be a line
+			if len(out) == 0 {
+				return nil, fmt.Errorf("sharp line without a previous line")
+			}
+			if prev, ok := out[len(out)-1].(elementNode); !ok || prev.kind != lineElement {
+				return nil, fmt.Errorf("sharp line without a previous line")
+			}
+		}
+		switch p.peek() {
+		case '?':
+			atom = repeatNode{atom, 0, 1}
+			p.pos++
+		case '+':
+			atom = repeatNode{atom, 1, -1}
+			p.pos++
+		case '*':
+			atom = repeatNode{atom, 0, -1}
+			p.pos++
+		}
+		out = append(out, atom)
+	}
+}
+
+func (p *templateParser) parseAtom() (templateNode, error) {
+	if p.peek() == '(' {
+		p.pos++
+		var out alternativeNode
+		for {
+			seq, err := p.parseSequence()
+			if err != nil {
+				return nil, err
+			}
+			if len(seq) == 0 {
+				return nil, fmt.Errorf("empty alternative")
+			}
+			out = append(out, seq)
+			switch p.peek() {
+			case '|':
+				p.pos++
+			case ')':
+				p.pos++
+				return out, nil
+			default:
+				return nil, fmt.Errorf("missing closing parenthesis")
+			}
+		}
+	}
+
+	name := p.parseName()
+	var el elementNode
+	switch name {
+	case "line":
+		el.kind = lineElement
+	case "curve":
+		el.kind = curveElement
+	case "hook":
+		el.kind = hookElement
+	case "cup":
+		el.kind = cupElement
+	case "any":
+		el.kind = anyElement
+	case "":
+		return nil, fmt.Errorf("unexpected %q", p.src[p.pos])
+	default:
+		return nil, fmt.Errorf("unknown element %q", name)
+	}
+	for p.pos < len(p.src) && p.src[p.pos] == '.' {
+		p.pos++
+		q := p.parseName()
+		if !el.kind.accepts(q) {
+			return nil, fmt.Errorf("invalid qualifier %q for %s", q, name)
+		}
+		el.qualifiers = append(el.qualifiers, q)
+	}
+	return el, nil
+}
+
+func (p *templateParser) parseName() string {
+	p.skipSpaces()
+	start := p.pos
+	for p.pos < len(p.src) && unicode.IsLetter(p.src[p.pos]) {
+		p.pos++
+	}
+	return string(p.src[start:p.pos])
+}
+
+// ---------------------------- matching ----------------------------
+
+// templateStroke is the input of the matching
+type templateStroke struct {
+	curves []Bezier
+	length Fl // total arc length
+}
+
+// templateNode matches the curves starting at [pos], calling [next] with
+// each possible end position, until it returns true
+type templateNode interface {
+	match(st *templateStroke, pos int, next func(int) bool) bool
+}
+
+// matchStrokeNode returns true if [node] matches all the curves of [st]
+func matchStrokeNode(node templateNode, st Stroke) bool {
+	ts := templateStroke{curves: st.Curves}
+	for _, cu := range st.Curves {
+		ts.length += cu.arcLength()
+	}
+	return node.match(&ts, 0, func(end int) bool { return end == len(st.Curves) })
+}
+
+type sequenceNode []templateNode
+
+func (sn sequenceNode) match(st *templateStroke, pos int, next func(int) bool) bool {
+	if len(sn) == 0 {
+		return next(pos)
+	}
+	return sn[0].match(st, pos, func(end int) bool { return sn[1:].match(st, end, next) })
+}
+
+type alternativeNode []sequenceNode
+
+func (an alternativeNode) match(st *templateStroke, pos int, next func(int) bool) bool {
+	for _, seq := range an {
+		if seq.match(st, pos, next) {
+			return true
+		}
+	}
+	return false
+}
+
+type repeatNode struct {
+	node     templateNode
+	min, max int // max is -1 for no limit
+}
+
+func (rn repeatNode) match(st *templateStroke, pos int, next func(int) bool) bool {
+	var aux func(pos, count int) bool
+	aux = func(pos, count int) bool {
+		// try the longest repetition first
+		if rn.max == -1 || count < rn.max {
+			if rn.node.match(st, pos, func(end int) bool { return end > pos && aux(end, count+1) }) {
+				return true
+			}
+		}
+		return count >= rn.min && next(pos)
+	}
+	return aux(pos, 0)
+}
+
+type elementKind uint8
+
+const (
+	lineElement elementKind = iota
+	curveElement
+	hookElement
+	cupElement
+	anyElement
+)
+
+func (ek elementKind) accepts(qualifier string) bool {
+	switch ek {
+	case lineElement:
+		switch qualifier {
+		case "h", "v", "left", "right", "up", "down", "sharp":
+			return true
+		}
+	case curveElement, hookElement:
+		switch qualifier {
+		case "left", "right", "up", "down":
+			return true
+		}
+	}
+	return false
+}
+
+type elementNode struct {
+	kind       elementKind
+	qualifiers []string
+}
+
+func (en elementNode) match(st *templateStroke, pos int, next func(int) bool) bool {
+	if pos >= len(st.curves) || !en.matchCurve(st, pos) {
+		return false
+	}
+	return next(pos + 1)
+}
+
+func (en elementNode) matchCurve(st *templateStroke, pos int) bool {
+	cu := st.curves[pos]
+	switch en.kind {
+	case lineElement:
+		if !cu.IsRoughlyLinear() {
+			return false
+		}
+		if en.hasQualifier("sharp") { // the previous curve is a line, see parseSequence
+			if pos == 0 {
+				return false
+			}
+			previous := st.curves[pos-1]
+			if a := angle(previous.P0.Sub(previous.P3), cu.P3.Sub(cu.P0)); !(0 <= a && a <= templateSharpAngle) {
+				return false
+			}
+		}
+		return en.checkDirection(cu.P3.Sub(cu.P0), true)
+	case curveElement:
+		if cu.IsRoughlyLinear() {
+			return false
+		}
+		return en.checkDirection(cu.bulge(), false)
+	case hookElement:
+		if cu.IsRoughlyLinear() || cu.arcLength() > templateHookLength*st.length {
+			return false
+		}
+		return en.checkDirection(cu.bulge(), false)
+	case cupElement:
+		return cu.isCup()
+	default: // any
+		return true
+	}
+}
+
+// checkDirection checks the qualifiers against [dir]
+func (en elementNode) checkDirection(dir Pos, isLine bool) bool {
+	tanH := Fl(math.Tan(templateHorizontalTolerance * math.Pi / 180))
+	tanV := Fl(math.Tan(templateVerticalTolerance * math.Pi / 180))
+	for _, q := range en.qualifiers {
+		var ok bool
+		switch q {
+		case "h":
+			ok = abs(dir.Y) <= tanH*abs(dir.X)
+		case "v":
+			ok = abs(dir.X) <= tanV*abs(dir.Y)
+		case "left":
+			ok = dir.X < 0 && (isLine || abs(dir.X) >= abs(dir.Y))
+		case "right":
+			ok = dir.X > 0 && (isLine || abs(dir.X) >= abs(dir.Y))
+		case "up": // +Y is downward
+			ok = dir.Y < 0 && (isLine || abs(dir.Y) >= abs(dir.X))
+		case "down":
+			ok = dir.Y > 0 && (isLine || abs(dir.Y) >= abs(dir.X))
+		case "sharp": // checked by matchCurve
+			ok = true
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (en elementNode) hasQualifier(q string) bool {
+	for _, other := range en.qualifiers {
+		if other == q {
+			return true
+		}
+	}
+	return false
+}
+
+// bulge returns the vector from the middle of the chord
+// to the middle of the curve
+func (U Bezier) bulge() Pos {
+	return U.pointAt(0.5).Sub(U.P0.Add(U.P3).ScaleTo(0.5))
+}
+
+// isCup returns true for a U shape, drawn from left to right
+func (U Bezier) isCup() bool {
+	startX, endX := U.P0.X, U.P3.X
+	return startX < endX && startX <= U.P1.X && U.P2.X <= endX &&
+		U.P1.Y > U.P0.Y && U.P2.Y > U.P3.Y // +Y is downward
+}
@@ -0,0 +1,121 @@
+package symbols
+
+import (
+	"testing"
+
+	tu "github.com/benoitkugler/pen2latex/testutils"
+)
+
+// strokeOf returns a footprint with one stroke per list of curves
+func strokesOf(curves ...[]Bezier) Footprint {
+	var out Footprint
+	for _, cs := range curves {
+		out.Strokes = append(out.Strokes, Stroke{Curves: cs})
+	}
+	return out
+}
+
+// polyline returns the segments joining [points]
+func polyline(points ...Pos) []Bezier {
+	var out []Bezier
+	for i := 1; i < len(points); i++ {
+		out = append(out, segment{points[i-1], points[i]}.asBezier())
+	}
+	return out
+}
+
+func TestParseTemplate(t *testing.T) {
+	for _, rule := range []string{
+		"line",
+		"line.h.right curve.left",
+		"(line.down line.up | cup) line.h.right",
+		"(line.down line.up.sharp | cup) line.h.right",
+		"line line.sharp+",
+		"tall big: hook? line.v hook*",
+		"wide: line; (line | curve)+",
+	} {
+		_, err := ParseTemplate('x', rule)
+		tu.AssertNoErr(t, err)
+	}
+
+	for _, rule := range []string{
+		"",
+		"line;",
+		"square",
+		"cup.left",
+		"line.diagonal",
+		"huge: line",
+		"(line | curve",
+		"(line | )",
+		"line | curve",
+		"line )",
+		"line.sharp",
+		"(line.sharp | cup)",
+		"cup line.sharp",
+		"line? line.sharp",
+		"(line | cup) line.sharp",
+	} {
+		_, err := ParseTemplate('x', rule)
+		tu.Assert(t, err != nil)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	grid := HeightGrid{Ymin: 0, Ymax: 90, Baseline: 60}
+
+	vertical := polyline(Pos{20, 0}, Pos{22, 80})
+	bracket := polyline(Pos{30, 0}, Pos{20, 0}, Pos{20, 80}, Pos{30, 80})
+	parenthesis := []Bezier{{P0: Pos{20, 0}, P1: Pos{0, 20}, P2: Pos{0, 60}, P3: Pos{20, 80}}}
+	integral := []Bezier{
+		{P0: Pos{26, 4}, P1: Pos{25, 0}, P2: Pos{21, 0}, P3: Pos{20, 4}},
+		segment{Pos{20, 4}, Pos{18, 76}}.asBezier(),
+		{P0: Pos{18, 76}, P1: Pos{17, 80}, P2: Pos{13, 80}, P3: Pos{12, 76}},
+	}
+	sqrt := polyline(Pos{0, 50}, Pos{10, 70}, Pos{20, 20}, Pos{60, 20})
+	arrowHead := polyline(Pos{90, 40}, Pos{100, 50}, Pos{90, 60})
+	shaft := polyline(Pos{0, 50}, Pos{100, 50})
+
+	for _, test := range []struct {
+		input    Footprint
+		grid     HeightGrid
+		expected rune // 0 for no match
+	}{
+		{strokesOf(vertical), grid, '|'},
+		{strokesOf(vertical), HeightGrid{}, 0},                  // no size reference
+		{strokesOf(polyline(Pos{20, 0}, Pos{21, 30})), grid, 0}, // too small
+		{strokesOf(vertical, vertical), grid, '‖'},
+		{strokesOf(bracket), grid, '['},
+		{strokesOf(parenthesis), grid, '('},
+		{strokesOf(integral), grid, '∫'},
+		{strokesOf(integral), HeightGrid{}, 0}, // no size reference
+		{strokesOf(sqrt), HeightGrid{}, '√'},
+		{strokesOf(polyline(Pos{0, 50}, Pos{30, 70}, Pos{60, 20}, Pos{100, 20})), HeightGrid{}, 0}, // the V is too wide
+		{strokesOf(shaft, arrowHead), grid, '⟶'},
+		{strokesOf(polyline(Pos{0, 0}, Pos{0, 80}, Pos{40, 80})), grid, 0}, // L
+	} {
+		r, ok := DefaultTemplates.Match(test.input, test.grid)
+		tu.AssertEqual(t, ok, test.expected != 0)
+		tu.AssertEqual(t, r, test.expected)
+	}
+
+	// one stroke may be the beginning of a template
+	tu.Assert(t, DefaultTemplates.IsPrefix(strokesOf(vertical), grid))
+	tu.Assert(t, DefaultTemplates.IsPrefix(strokesOf(shaft), grid))
+	tu.Assert(t, !DefaultTemplates.IsPrefix(strokesOf(sqrt), grid))
+	tu.Assert(t, !DefaultTemplates.IsPrefix(strokesOf(vertical, vertical), grid))
+
+	// repetitions
+	twoArcs := []Bezier{
+		{P0: Pos{20, 0}, P1: Pos{5, 5}, P2: Pos{0, 25}, P3: Pos{2, 40}},
+		{P0: Pos{2, 40}, P1: Pos{0, 55}, P2: Pos{5, 75}, P3: Pos{20, 80}},
+	}
+	tu.AssertEqual(t, MustParseTemplate('(', "curve.left+").Match(strokesOf(twoArcs), grid), true)
+	tu.AssertEqual(t, MustParseTemplate('(', "curve.left").Match(strokesOf(twoArcs), grid), false)
+	tu.AssertEqual(t, MustParseTemplate('(', "curve.left curve.left? line*").Match(strokesOf(twoArcs), grid), true)
+	tu.AssertEqual(t, MustParseTemplate(')', "curve.right+").Match(strokesOf(twoArcs), grid), false)
+
+	// an overline, not in the default templates
+	overline := MustParseTemplate('‾', "wide big: line.h")
+	tu.Assert(t, overline.Match(strokesOf(shaft), grid))
+	tu.Assert(t, !overline.Match(strokesOf(vertical), grid))
+}
@@ -0,0 +1,289 @@
+package symbols
+
+import (
+	"context"
+	"math"
+	"math/bits"
+)
+
+// This file implements a detailed report of the matching done by [Store.LookupTrace],
+// useful to understand why a symbol is (or is not) recognized.
+// The report may be encoded to JSON : since JSON does not support infinite values,
+// the infinite (or undefined) distances and components are reported as -1.
+
+// Trace is the report of one lookup.
+type Trace struct {
+	// Entries lists the entries of the store compared with the input,
+	// in the order of [Store.Symbols], the exact matches coming first.
+	Entries []EntryTrace `json:"entries"`
+
+	// the result of the lookup, as returned by [Store.Lookup]
+	R          rune `json:"rune"`
+	Distance   Fl   `json:"distance"`
+	Compatible bool `json:"compatible"`
+
+	// Candidates are the ranked candidates, as returned by [Store.LookupN]
+	// (without limit)
+	Candidates []Candidate `json:"candidates"`
+}
+
+// EntryTrace details the comparison of the input with one entry of the store.
+type EntryTrace struct {
+	Index int  `json:"index"` // in [Store.Symbols]
+	R     rune `json:"rune"`
+
+	// Compatible is true if only the first strokes of the entry
+	// have been compared with the input.
+	Compatible bool `json:"compatible"`
+
+	// Distance is the distance between the input and the entry
+	Distance Fl `json:"distance"`
+	// ClassPenalty is the factor (at least 1) applied to [Distance]
+	// in the vote, see [Candidate.ClassPenalty]
+	ClassPenalty Fl `json:"classPenalty"`
+
+	// Strokes is the correspondence between the strokes of the entry and
+	// the strokes of the input. It is only reported for [BezierDistance], and when
+	// the distance is finite.
+	Strokes []StrokeMatch `json:"strokes,omitempty"`
+}
+
+// StrokeMatch is a group of strokes matched together by [BezierDistance] : usually one
+// stroke of the entry and one of the input, but one stroke may also be
+// matched with two when [BezierDistance.Unordered] is true.
+type StrokeMatch struct {
+	Entry []int `json:"entry"` // the indices of the strokes of the entry
+	Input []int `json:"input"` // the indices of the strokes of the input
+
+	// Reversed is true if the strokes of the entry are
+	// matched in the opposite direction
+	Reversed bool `json:"reversed"`
+
+	Distance   Fl                 `json:"distance"`
+	Components DistanceComponents `json:"components"`
+}
+
+// DistanceComponents details a distance computed by [BezierDistance] between
+// two strokes or two curves, which is (Derivative + Curvature + Points + Controls) * Penalty.
+// The terms are already multiplied by their [BezierWeights] and, for strokes,
+// averaged over the curves, weighted by their arc length.
+type DistanceComponents struct {
+	Derivative Fl `json:"derivative"`
+	Curvature  Fl `json:"curvature"`
+	Points     Fl `json:"points"`
+	Controls   Fl `json:"controls"`
+	// Penalty is the multiplicative factor (at least 1) combining
+	// the angle and line penalties of the curves and the shape penalties of the strokes
+	// (start lines, circles, loops, merged strokes)
+	Penalty Fl `json:"penalty"`
+}
+
+func (dc DistanceComponents) sum() Fl {
+	return dc.Derivative + dc.Curvature + dc.Points + dc.Controls
+}
+
+func (dc DistanceComponents) total() Fl { return dc.sum() * dc.Penalty }
+
+// addScaled adds the terms of [other], multiplied by [factor]
+func (dc *DistanceComponents) addScaled(other DistanceComponents, factor Fl) {
+	dc.Derivative += other.Derivative * factor
+	dc.Curvature += other.Curvature * factor
+	dc.Points += other.Points * factor
+	dc.Controls += other.Controls * factor
+}
+
+// average divides the terms by [totalLength] and sets
+// the penalty so that the total is [distance]
+func (dc DistanceComponents) average(totalLength, distance Fl) DistanceComponents {
+	dc.Derivative /= totalLength
+	dc.Curvature /= totalLength
+	dc.Points /= totalLength
+	dc.Controls /= totalLength
+	dc.Penalty = 1
+	if s := dc.sum(); s > 0 && s < Inf {
+		dc.Penalty = Max(distance/s, 1) // avoid rounding errors
+	}
+	return dc
+}
+
+// finite returns -1 for infinite or NaN distances
+func finite(d Fl) Fl {
+	if d >= Inf || math.IsNaN(float64(d)) {
+		return -1
+	}
+	return d
+}
+
+// finite applies [finite] to every term
+func (dc DistanceComponents) finite() DistanceComponents {
+	return DistanceComponents{
+		Derivative: finite(dc.Derivative),
+		Curvature:  finite(dc.Curvature),
+		Points:     finite(dc.Points),
+		Controls:   finite(dc.Controls),
+		Penalty:    finite(dc.Penalty),
+	}
+}
+
+// LookupTrace is the same as [Store.Lookup], but also returns
+// a report of the comparisons performed.
+// It is slower than [Store.Lookup], and should only be used for debugging.
+func (db *Store) LookupTrace(input Footprint, grid HeightGrid) (rune, Fl, bool, Trace) {
+	idx := db.getIndex()
+	prepared := db.prepareInput(idx, input)
+	exactCandidates, compatibleCandidates := db.candidates(idx, prepared)
+	distances, distancesCompatible := db.distancesFor(idx, prepared, exactCandidates, compatibleCandidates)
+	r, d, isCompatible, trace, _ := db.lookupTrace(context.Background(), idx, prepared, input, grid,
+		exactCandidates, compatibleCandidates, distances, distancesCompatible)
+	return r, d, isCompatible, trace
+}
+
+// lookupTrace performs the lookup and builds its report, from the distances
+// already computed. It stops early if [ctx] is cancelled.
+func (db *Store) lookupTrace(ctx context.Context, idx *storeIndex, prepared matchInput, input Footprint, grid HeightGrid,
+	exactCandidates, compatibleCandidates []int, distances, distancesCompatible []Fl,
+) (rune, Fl, bool, Trace, error) {
+	r, d, isCompatible := db.lookup(input, grid, distances, distancesCompatible)
+	penalties := db.classPenalties(input, grid)
+
+	trace := Trace{R: r, Distance: finite(d), Compatible: isCompatible}
+	trace.Candidates = db.rank(input, grid, distances, distancesCompatible, len(db.Symbols))
+	for _, i := range exactCandidates {
+		if err := ctx.Err(); err != nil {
+			return 0, Inf, false, Trace{}, err
+		}
+		entry := db.entryTrace(idx, i, prepared, false, distances[i])
+		entry.ClassPenalty = penaltyAt(penalties, i)
+		trace.Entries = append(trace.Entries, entry)
+	}
+	for _, i := range compatibleCandidates {
+		if err := ctx.Err(); err != nil {
+			return 0, Inf, false, Trace{}, err
+		}
+		entry := db.entryTrace(idx, i, prepared, true, distancesCompatible[i])
+		entry.ClassPenalty = 1
+		trace.Entries = append(trace.Entries, entry)
+	}
+	return r, d, isCompatible, trace, nil
+}
+
+// entryTrace reports the comparison of the entry [i] with [input], whose distance is [d]
+func (db *Store) entryTrace(idx *storeIndex, i int, input matchInput, compatible bool, d Fl) EntryTrace {
+	out := EntryTrace{Index: i, R: db.Symbols[i].R, Compatible: compatible, Distance: finite(d)}
+	bd, isBezier := db.metric().(BezierDistance)
+	if !isBezier || d >= Inf {
+		return out
+	}
+
+	entry := db.entry(idx, i)
+	if compatible {
+		entry.Strokes = entry.Strokes[:len(input.fp.Strokes)]
+	}
+	// use the tolerated rotation with the smallest distance, as [Store.entryDistance] does
+	fp, best := input.fp, bd.Symbol(entry, input.fp)
+	for _, rotated := range input.rotated[db.Rotation.Bound(db.Symbols[i].R)] {
+		if dr := bd.Symbol(entry, rotated); dr < best {
+			fp, best = rotated, dr
+		}
+	}
+	out.Strokes = bd.matches(entry, fp)
+	return out
+}
+
+// matches returns the correspondence between the strokes
+// used to compute the distance between [U] and [V]
+func (bd BezierDistance) matches(U, V Footprint) []StrokeMatch {
+	w := bd.Weights.orDefault()
+	if bd.Unordered {
+		return w.matchesUnordered(U, V, bd.Budget)
+	}
+	return w.matchesExact(U, V)
+}
+
+// matchesExact follows [BezierWeights.distanceSymbolsExact]
+func (w BezierWeights) matchesExact(U, V Footprint) []StrokeMatch {
+	if len(U.Strokes) != len(V.Strokes) {
+		return nil
+	}
+	tr := mapFromTo(U.controlBox(), V.controlBox())
+	Uscaled := make([]Stroke, len(U.Strokes))
+	order := make([]int, len(U.Strokes))
+	for i, s := range U.Strokes {
+		Uscaled[i] = s.scale(tr)
+		order[i] = i
+	}
+
+	dist, out := w.matchStrokes(Uscaled, V.Strokes, order)
+	if len(Uscaled) == 2 && len(Uscaled[0].Curves) == 1 && len(Uscaled[1].Curves) == 1 &&
+		len(V.Strokes[0].Curves) == 1 && len(V.Strokes[1].Curves) == 1 {
+		permutated := []Stroke{Uscaled[1], Uscaled[0]}
+		if d, matches := w.matchStrokes(permutated, V.Strokes, []int{1, 0}); d < dist {
+			out = matches
+		}
+	}
+	return out
+}
+
+// matchStrokes follows [BezierWeights.distanceStrokes], where the
+// stroke s1[i] is the stroke order[i] of the entry
+func (w BezierWeights) matchStrokes(s1, s2 []Stroke, order []int) (Fl, []StrokeMatch) {
+	var totalDistance Fl
+	out := make([]StrokeMatch, len(s1))
+	for i := range s1 {
+		out[i] = w.matchStroke(s1[i], s2[i])
+		out[i].Entry, out[i].Input = []int{order[i]}, []int{i}
+		totalDistance += out[i].Distance
+	}
+	for i := range out {
+		out[i].Distance = finite(out[i].Distance)
+		out[i].Components = out[i].Components.finite()
+	}
+	return totalDistance / Fl(len(s1)), out
+}
+
+// matchStroke compares [u] and [v], accepting [u] drawn in the opposite direction.
+// The indices of the strokes are not set.
+func (w BezierWeights) matchStroke(u, v Stroke) StrokeMatch {
+	var regular, reversed DistanceComponents
+	d1 := w.strokeDistance(u, v, &regular)
+	d2 := w.strokeDistance(u.reverse(), v, &reversed)
+	if d2 < d1 {
+		return StrokeMatch{Reversed: true, Distance: d2, Components: reversed}
+	}
+	return StrokeMatch{Distance: d1, Components: regular}
+}
+
+// matchesUnordered follows [BezierWeights.distanceSymbolsUnordered]
+func (w BezierWeights) matchesUnordered(U, V Footprint, budget int) []StrokeMatch {
+	as, ok := w.assignStrokes(U, V, budget)
+	if !ok {
+		return nil
+	}
+	out := make([]StrokeMatch, len(as.bestPath))
+	for i, g := range as.bestPath {
+		u, mergedU := joinStrokes(as.U, g.u)
+		v, mergedV := joinStrokes(as.V, g.v)
+		match := w.matchStroke(u, v)
+		if mergedU || mergedV {
+			match.Distance *= mergePenalty
+			match.Components.Penalty *= mergePenalty
+		}
+		match.Distance = finite(match.Distance)
+		match.Components = match.Components.finite()
+		match.Entry, match.Input = maskIndices(g.u, as.indicesU), maskIndices(g.v, as.indicesV)
+		out[i] = match
+	}
+	return out
+}
+
+// maskIndices returns the indices of the bits set in [mask],
+// mapped by [indices]
+func maskIndices(mask uint64, indices []int) []int {
+	var out []int
+	for mask != 0 {
+		i := bits.TrailingZeros64(mask)
+		out = append(out, indices[i])
+		mask &^= 1 << i
+	}
+	return out
+}
@@ -0,0 +1,90 @@
+package symbols
+
+import (
+	"encoding/json"
+	"testing"
+
+	tu "github.com/benoitkugler/pen2latex/testutils"
+)
+
+func TestLookupTrace(t *testing.T) {
+	db := testStoreAllSamples()
+	for _, group := range symbols {
+		input := group.symbols[0].Footprint()
+		r, d, compatible := db.Lookup(input, HeightGrid{})
+		rt, dt, compatibleT, trace := db.LookupTrace(input, HeightGrid{})
+		tu.AssertEqual(t, rt, r)
+		tu.AssertEqual(t, dt, d)
+		tu.AssertEqual(t, compatibleT, compatible)
+		tu.AssertEqual(t, trace.R, r)
+		tu.Assert(t, len(trace.Entries) != 0)
+
+		for _, entry := range trace.Entries {
+			tu.AssertEqual(t, entry.R, db.Symbols[entry.Index].R)
+			if entry.Distance == -1 {
+				tu.AssertEqual(t, len(entry.Strokes), 0)
+				continue
+			}
+			// the distance is the average of the stroke distances
+			tu.AssertEqual(t, len(entry.Strokes), len(input.Strokes))
+			var sum Fl
+			for _, match := range entry.Strokes {
+				sum += match.Distance
+				tu.Assert(t, match.Components.Penalty >= 1)
+				tu.Assert(t, abs(match.Components.total()-match.Distance) <= 1e-3*(1+match.Distance))
+			}
+			tu.Assert(t, abs(sum/Fl(len(entry.Strokes))-entry.Distance) <= 1e-3*(1+entry.Distance))
+		}
+
+		_, err := json.Marshal(trace)
+		tu.AssertNoErr(t, err)
+	}
+}
+
+func TestTraceFiniteComponents(t *testing.T) {
+	point := Stroke{Curves: []Bezier{{Pos{10, 10}, Pos{10, 10}, Pos{10, 10}, Pos{10, 10}}}}
+	line := Stroke{Curves: []Bezier{{Pos{0, 0}, Pos{10, 0}, Pos{20, 0}, Pos{30, 0}}}}
+	_, matches := DefaultBezierWeights.matchStrokes([]Stroke{point}, []Stroke{line}, []int{0})
+	tu.AssertEqual(t, matches[0].Distance, Fl(-1))
+	tu.AssertEqual(t, matches[0].Components.Points, Fl(-1))
+	_, err := json.Marshal(matches)
+	tu.AssertNoErr(t, err)
+}
+
+func TestTraceStrokeOrder(t *testing.T) {
+	var (
+		horizontal = generateSegment(Pos{0, 20}, Pos{40, 20})
+		vertical   = generateSegment(Pos{20, 0}, Pos{20, 40})
+	)
+	var db Store
+	db.Add('+', Symbol{horizontal, vertical})
+
+	// the strokes are swapped, the vertical being reversed
+	input := Symbol{vertical.reverse(), horizontal}.Footprint()
+	r, _, _, trace := db.LookupTrace(input, HeightGrid{})
+	tu.AssertEqual(t, r, '+')
+	tu.AssertEqual(t, len(trace.Entries), 1)
+	strokes := trace.Entries[0].Strokes
+	tu.AssertEqual(t, len(strokes), 2)
+	tu.AssertEqual(t, strokes[0].Entry, []int{1})
+	tu.AssertEqual(t, strokes[0].Input, []int{0})
+	tu.AssertEqual(t, strokes[0].Reversed, true)
+	tu.AssertEqual(t, strokes[1].Entry, []int{0})
+	tu.AssertEqual(t, strokes[1].Reversed, false)
+
+	// with the unordered matching, two strokes may be matched with one
+	db.Distance = BezierDistance{Unordered: true}
+	db.Add('L', Symbol{append(generateSegment(Pos{0, 0}, Pos{0, 40}), generateSegment(Pos{0, 40}, Pos{25, 40})[1:]...)})
+	input = Symbol{generateSegment(Pos{0, 0}, Pos{0, 40}), generateSegment(Pos{0, 40}, Pos{25, 40})}.Footprint()
+	r, _, _, trace = db.LookupTrace(input, HeightGrid{})
+	tu.AssertEqual(t, r, 'L')
+	for _, entry := range trace.Entries {
+		if entry.R != 'L' {
+			continue
+		}
+		tu.AssertEqual(t, len(entry.Strokes), 1)
+		tu.AssertEqual(t, entry.Strokes[0].Entry, []int{0})
+		tu.AssertEqual(t, entry.Strokes[0].Input, []int{0, 1})
+		tu.Assert(t, entry.Strokes[0].Components.Penalty >= mergePenalty)
+	}
+}
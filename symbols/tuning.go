@@ -0,0 +1,129 @@
+package symbols
+
+// LabelledSymbol is a symbol drawn by the user,
+// with the rune it should be recognized as.
+//
+// The records of the layout package can't be used here, since it depends on
+// this package : see layout.TuneBezierWeights to tune from records.
+type LabelledSymbol struct {
+	R      rune
+	Symbol Symbol
+}
+
+// DefaultTuningRounds is the number of passes used by [TuneBezierWeights]
+// when not specified.
+const DefaultTuningRounds = 3
+
+// tuningFactors are the multiplicative steps tried for each weight
+var tuningFactors = [...]Fl{0.5, 2, 0.8, 1.25}
+
+// tuningValues returns the values tried for a weight, currently set to [value].
+// Since a zero weight can't be scaled, the steps are then applied
+// to its default value [def].
+func tuningValues(value, def Fl) []Fl {
+	out := make([]Fl, 0, len(tuningFactors)+1)
+	if value == 0 {
+		value = def
+		out = append(out, def)
+	}
+	for _, factor := range tuningFactors {
+		out = append(out, value*factor)
+	}
+	return out
+}
+
+// fields returns pointers to the weights, in a fixed order
+func (w *BezierWeights) fields() []*Fl {
+	return []*Fl{
+		&w.Derivative, &w.Curvature, &w.Points, &w.Controls,
+		&w.InnerControls, &w.AnglePenalty, &w.LinePenalty,
+	}
+}
+
+// TuneBezierWeights optimizes the weights used by [BezierDistance] to maximise
+// the leave-one-out accuracy on [corpus] : each symbol should be closer to
+// another symbol with the same rune than to the symbols of the other runes.
+//
+// A coordinate descent is performed, starting from [DefaultBezierWeights] : for each
+// weight in turn, several multiplicative steps are tried and the best one is kept.
+// Zero weights are stepped from their default value.
+// At most [rounds] passes are done (if zero, [DefaultTuningRounds] is used),
+// stopping early when no weight is improved.
+//
+// The learned weights are returned with their accuracy, which is -1
+// if no rune has at least two samples (and the weights are then not changed).
+//
+// The complexity is quadratic in the size of the corpus.
+func TuneBezierWeights(corpus []LabelledSymbol, rounds int) (BezierWeights, Fl) {
+	labels := make([]rune, len(corpus))
+	fps := make([]Footprint, len(corpus))
+	for i, sample := range corpus {
+		labels[i] = sample.R
+		fps[i] = sample.Symbol.Footprint()
+	}
+	return tuneWeights(labels, fps, DefaultBezierWeights, rounds)
+}
+
+// TuneWeights learns the [Store.Weights] from the samples
+// of the store, as [TuneBezierWeights] does, starting from the current weights,
+// and returns the accuracy obtained.
+// The calibration is then updated, since it depends on the metric.
+//
+// Note that the weights are only used when [Store.Distance] is nil.
+func (db *Store) TuneWeights(rounds int) Fl {
+	idx := db.getIndex()
+	var (
+		labels []rune
+		fps    []Footprint
+	)
+	for i, entry := range db.Symbols {
+		if idx.active[i] {
+			labels = append(labels, entry.R)
+			fps = append(fps, db.entry(idx, i))
+		}
+	}
+
+	weights, accuracy := tuneWeights(labels, fps, db.Weights.orDefault(), rounds)
+	if accuracy == -1 {
+		return -1
+	}
+	db.Weights = weights
+	if !db.Calibration.IsZero() {
+		db.Calibrate()
+	}
+	return accuracy
+}
+
+func tuneWeights(labels []rune, fps []Footprint, start BezierWeights, rounds int) (BezierWeights, Fl) {
+	if rounds <= 0 {
+		rounds = DefaultTuningRounds
+	}
+	accuracy := func(w BezierWeights) Fl {
+		return leaveOneOutAccuracy(BezierDistance{Weights: w}, labels, fps)
+	}
+
+	best := start
+	bestAccuracy := accuracy(best)
+	if bestAccuracy == -1 || bestAccuracy == 1 {
+		return best, bestAccuracy
+	}
+
+	for round := 0; round < rounds; round++ {
+		improved := false
+		defaults := DefaultBezierWeights.fields()
+		for field := range best.fields() {
+			for _, value := range tuningValues(*best.fields()[field], *defaults[field]) {
+				candidate := best
+				*candidate.fields()[field] = value
+				if acc := accuracy(candidate); acc > bestAccuracy {
+					best, bestAccuracy = candidate, acc
+					improved = true
+				}
+			}
+		}
+		if !improved || bestAccuracy == 1 {
+			break
+		}
+	}
+	return best, bestAccuracy
+}
@@ -0,0 +1,81 @@
+package symbols
+
+import (
+	"testing"
+
+	tu "github.com/benoitkugler/pen2latex/testutils"
+)
+
+func testCorpus() []LabelledSymbol {
+	var corpus []LabelledSymbol
+	for _, group := range symbols {
+		for _, sy := range group.symbols {
+			corpus = append(corpus, LabelledSymbol{R: rune(group.description[0]), Symbol: sy})
+		}
+	}
+	return corpus
+}
+
+func TestTuneBezierWeights(t *testing.T) {
+	// not enough samples
+	w, acc := TuneBezierWeights([]LabelledSymbol{{R: 'a', Symbol: symbols[0].symbols[0]}}, 0)
+	tu.AssertEqual(t, acc, Fl(-1))
+	tu.AssertEqual(t, w, DefaultBezierWeights)
+
+	corpus := testCorpus()
+	w, acc = TuneBezierWeights(corpus, 0)
+	tu.AssertEqual(t, acc, Fl(1))
+	tu.AssertEqual(t, w, DefaultBezierWeights)
+
+	// mislabel some samples so that the accuracy may not be perfect
+	labels := make([]rune, len(corpus))
+	fps := make([]Footprint, len(corpus))
+	for i, sample := range corpus {
+		labels[i] = sample.R
+		fps[i] = sample.Symbol.Footprint()
+	}
+	for i := 0; i < len(labels); i += 4 {
+		labels[i] = 'z'
+	}
+	initial := leaveOneOutAccuracy(BezierDistance{}, labels, fps)
+	w, acc = tuneWeights(labels, fps, DefaultBezierWeights, 1)
+	tu.Assert(t, acc >= initial)
+	tu.AssertEqual(t, leaveOneOutAccuracy(BezierDistance{Weights: w}, labels, fps), acc)
+}
+
+func TestTuningValues(t *testing.T) {
+	tu.AssertEqual(t, tuningValues(2, 10), []Fl{1, 4, 1.6, 2.5})
+	// zero weights may be tuned
+	tu.AssertEqual(t, tuningValues(0, 10), []Fl{10, 5, 20, 8, 12.5})
+
+	// a zero weight is restored when it improves the accuracy
+	corpus := testCorpus()
+	labels := make([]rune, len(corpus))
+	fps := make([]Footprint, len(corpus))
+	for i, sample := range corpus {
+		labels[i] = sample.R
+		fps[i] = sample.Symbol.Footprint()
+	}
+	start := BezierWeights{AnglePenalty: 1}
+	initial := leaveOneOutAccuracy(BezierDistance{Weights: start}, labels, fps)
+	w, acc := tuneWeights(labels, fps, start, 1)
+	tu.Assert(t, acc > initial)
+	tu.Assert(t, w.Derivative != 0 || w.Curvature != 0 || w.Points != 0 || w.Controls != 0)
+}
+
+func TestStoreTuneWeights(t *testing.T) {
+	var empty Store
+	tu.AssertEqual(t, empty.TuneWeights(0), Fl(-1))
+	tu.AssertEqual(t, empty.Weights, BezierWeights{})
+
+	db := testStoreAllSamples()
+	db.Calibrate()
+	tu.AssertEqual(t, db.TuneWeights(1), Fl(1))
+	tu.AssertEqual(t, db.Weights, DefaultBezierWeights)
+	tu.Assert(t, !db.Calibration.IsZero())
+
+	// the weights are saved with the store
+	db2, err := parseStore(encodeStore(db))
+	tu.AssertNoErr(t, err)
+	tu.AssertEqual(t, db2.Weights, db.Weights)
+}
@@ -0,0 +1,121 @@
+package symbols
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// This file implements the validation of the input shapes,
+// so that degenerate strokes (a stylus tap, a mis-registered stroke)
+// are reported instead of producing invalid footprints.
+
+var (
+	// ErrEmptySymbol is returned for a symbol without shapes.
+	ErrEmptySymbol = errors.New("symbol has no shape")
+	// ErrEmptyShape is returned for a shape without points.
+	ErrEmptyShape = errors.New("shape has no point")
+	// ErrInvalidPoint is returned for a shape with NaN or infinite coordinates.
+	ErrInvalidPoint = errors.New("shape has NaN or infinite coordinates")
+	// ErrSinglePoint is returned for a shape with only one point.
+	ErrSinglePoint = errors.New("shape has a single point")
+	// ErrZeroLength is returned for a shape whose points are all the same.
+	ErrZeroLength = errors.New("shape has zero length")
+)
+
+// ShapeError reports which shape of a [Symbol] is invalid.
+// [Err] is one of [ErrEmptyShape], [ErrInvalidPoint], [ErrSinglePoint] or [ErrZeroLength],
+// and may be tested with [errors.Is].
+type ShapeError struct {
+	Index int // index of the shape in the symbol
+	Err   error
+}
+
+func (se *ShapeError) Error() string { return fmt.Sprintf("shape %d: %s", se.Index, se.Err) }
+
+func (se *ShapeError) Unwrap() error { return se.Err }
+
+func isFinite(v Fl) bool { return !math.IsNaN(float64(v)) && !math.IsInf(float64(v), 0) }
+
+func (b Bezier) isFinite() bool {
+	for _, p := range [4]Pos{b.P0, b.P1, b.P2, b.P3} {
+		if !isFinite(p.X) || !isFinite(p.Y) {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate returns an error if the shape can't be fitted
+// into a meaningful [Stroke].
+func (sh Shape) Validate() error {
+	if len(sh) == 0 {
+		return ErrEmptyShape
+	}
+	for _, p := range sh {
+		if !isFinite(p.X) || !isFinite(p.Y) {
+			return ErrInvalidPoint
+		}
+	}
+	if len(sh) == 1 {
+		return ErrSinglePoint
+	}
+	for _, p := range sh[1:] {
+		if p != sh[0] {
+			return nil
+		}
+	}
+	return ErrZeroLength
+}
+
+// Validate returns a [*ShapeError] for the first invalid shape,
+// or [ErrEmptySymbol].
+func (sy Symbol) Validate() error {
+	if len(sy) == 0 {
+		return ErrEmptySymbol
+	}
+	for i, shape := range sy {
+		if err := shape.Validate(); err != nil {
+			return &ShapeError{Index: i, Err: err}
+		}
+	}
+	return nil
+}
+
+// NewFootprint is the same as [Symbol.Footprint], but uses [opts]
+// and returns an error for degenerate input, as reported by [Symbol.Validate]
+// or [FitStroke].
+func NewFootprint(sy Symbol, opts FitOptions) (Footprint, error) {
+	if err := opts.validate(); err != nil {
+		return Footprint{}, err
+	}
+	if err := sy.Validate(); err != nil {
+		return Footprint{}, err
+	}
+	strokes := make([]Stroke, len(sy))
+	for i, shape := range sy {
+		var err error
+		strokes[i], err = FitStroke(shape, opts)
+		if err != nil { // options are valid, so the error comes from the shape
+			return Footprint{}, &ShapeError{Index: i, Err: err}
+		}
+	}
+	return Footprint{Strokes: strokes}, nil
+}
+
+// sanitize removes the invalid points, so that
+// fitting never panics
+func (sh Shape) sanitize() Shape {
+	for _, p := range sh {
+		if !isFinite(p.X) || !isFinite(p.Y) {
+			out := make(Shape, 0, len(sh))
+			for _, p := range sh {
+				if isFinite(p.X) && isFinite(p.Y) {
+					out = append(out, p)
+				}
+			}
+			return out
+		}
+	}
+	return sh
+}
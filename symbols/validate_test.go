@@ -0,0 +1,143 @@
+package symbols
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	tu "github.com/benoitkugler/pen2latex/testutils"
+)
+
+func TestShapeValidate(t *testing.T) {
+	nan, inf := Fl(math.NaN()), Fl(math.Inf(-1))
+	for _, test := range []struct {
+		shape Shape
+		err   error
+	}{
+		{nil, ErrEmptyShape},
+		{Shape{{1, 2}}, ErrSinglePoint},
+		{Shape{{1, 2}, {1, 2}, {1, 2}}, ErrZeroLength},
+		{Shape{{1, 2}, {nan, 2}}, ErrInvalidPoint},
+		{Shape{{1, inf}}, ErrInvalidPoint},
+		{Shape{{1, 2}, {1, 3}}, nil},
+	} {
+		tu.Assert(t, test.shape.Validate() == test.err)
+
+		_, err := FitStroke(test.shape, DefaultFitOptions)
+		tu.Assert(t, errors.Is(err, test.err))
+	}
+
+	line := Shape{{0, 0}, {10, 10}}
+	tu.Assert(t, Symbol{}.Validate() == ErrEmptySymbol)
+	tu.AssertNoErr(t, Symbol{line}.Validate())
+
+	var se *ShapeError
+	err := Symbol{line, {{1, 1}}}.Validate()
+	tu.Assert(t, errors.As(err, &se) && se.Index == 1)
+	tu.Assert(t, errors.Is(err, ErrSinglePoint))
+
+	_, err = NewFootprint(Symbol{line, {{1, 1}, {1, 1}}}, DefaultFitOptions)
+	tu.Assert(t, errors.As(err, &se) && se.Index == 1)
+	tu.Assert(t, errors.Is(err, ErrZeroLength))
+}
+
+func TestFootprintDegenerate(t *testing.T) {
+	line := generateCircle(Pos{30, 30}, 20, 20)
+	nan := Fl(math.NaN())
+
+	// a dot is kept as a point, empty shapes are ignored
+	fp := Symbol{line, nil, {{50, 50}}, {{nan, nan}}}.Footprint()
+	tu.AssertEqual(t, len(fp.Strokes), 2)
+	dot := fp.Strokes[1]
+	_, isPoint := dot.IsPoint()
+	tu.Assert(t, isPoint)
+	tu.AssertEqual(t, dot.ArcLengths, []Fl{1})
+
+	// invalid points are ignored
+	withNaN := append(Shape{{nan, 0}}, line...)
+	tu.AssertEqual(t, Symbol{withNaN}.Footprint(), Symbol{line}.Footprint())
+
+	tu.AssertEqual(t, len(Symbol{nil}.Footprint().Strokes), 0)
+}
+
+// shapeFromBytes builds a symbol from raw data, using special
+// values for NaN, infinity and shape separators
+func shapeFromBytes(data []byte) Symbol {
+	var (
+		out   Symbol
+		shape Shape
+	)
+	for ; len(data) >= 2; data = data[2:] {
+		if data[0] == 255 {
+			out = append(out, shape)
+			shape = nil
+			continue
+		}
+		p := Pos{Fl(int8(data[0])), Fl(int8(data[1]))}
+		switch data[1] {
+		case 254:
+			p.Y = Fl(math.NaN())
+		case 253:
+			p.Y = Fl(math.Inf(1))
+		}
+		shape = append(shape, p)
+	}
+	return append(out, shape)
+}
+
+func isFiniteStroke(st Stroke) bool {
+	for _, c := range st.Curves {
+		if !c.isFinite() {
+			return false
+		}
+	}
+	for _, a := range st.ArcLengths {
+		if !isFinite(a) {
+			return false
+		}
+	}
+	return len(st.Curves) != 0 && len(st.Curves) == len(st.ArcLengths)
+}
+
+func addFuzzSeeds(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{1, 2})
+	f.Add([]byte{1, 2, 1, 2, 1, 2})
+	f.Add([]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+	f.Add([]byte{1, 254, 3, 4, 255, 0, 5, 6, 7, 253})
+	f.Add([]byte{0, 0, 0, 10, 0, 20, 0, 30, 0, 40, 255, 0, 10, 0})
+}
+
+func FuzzFootprint(f *testing.F) {
+	addFuzzSeeds(f)
+	store := testStoreAllSamples()
+	f.Fuzz(func(t *testing.T, data []byte) {
+		sy := shapeFromBytes(data)
+
+		fp := sy.Footprint()
+		for _, st := range fp.Strokes {
+			tu.Assert(t, len(st.Curves) != 0)
+		}
+		store.Lookup(fp, HeightGrid{})
+
+		fp, err := NewFootprint(sy, DefaultFitOptions)
+		if err == nil {
+			for _, st := range fp.Strokes {
+				tu.Assert(t, isFiniteStroke(st))
+			}
+		}
+	})
+}
+
+func FuzzFitStroke(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		sy := shapeFromBytes(data)
+		st, err := FitStroke(sy[0], DefaultFitOptions)
+		if err == nil {
+			tu.Assert(t, isFiniteStroke(st))
+		} else {
+			tu.Assert(t, errors.Is(err, sy[0].Validate()) || sy[0].Validate() == nil)
+		}
+	})
+}